@@ -2,300 +2,3365 @@ package main
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"math/rand"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"awesomeProject1/analyzer"
+	"github.com/parquet-go/parquet-go"
+	"github.com/robfig/cron/v3"
+)
+
+// Config structure for JSON config file
+type Config struct {
+	Folders []FolderConfig `json:"folders"`
+
+	// Options that would otherwise be repeated on every command line; flags given there win
+	Extensions []string `json:"extensions,omitempty"` // log file extensions to scan, like --ext
+	Pattern    string   `json:"pattern,omitempty"`    // like --pattern; a folder's own pattern still wins
+	Workers    int      `json:"workers,omitempty"`    // like --workers
+	Recursive  bool     `json:"recursive,omitempty"`  // like --recursive
+	Since      string   `json:"since,omitempty"`      // like --since, YYYY-MM-DD
+	Until      string   `json:"until,omitempty"`      // like --until, YYYY-MM-DD
+}
+
+// mergeOptions folds a later config file's options into c. Every option that other sets replaces
+// c's; recursive can only be turned on. Folders are left alone.
+func (c *Config) mergeOptions(other Config) {
+	if len(other.Extensions) > 0 {
+		c.Extensions = other.Extensions
+	}
+	if other.Pattern != "" {
+		c.Pattern = other.Pattern
+	}
+	if other.Workers != 0 {
+		c.Workers = other.Workers
+	}
+	c.Recursive = c.Recursive || other.Recursive
+	if other.Since != "" {
+		c.Since = other.Since
+	}
+	if other.Until != "" {
+		c.Until = other.Until
+	}
+}
+
+// FolderConfig is a folder entry from the config file, given either as a plain path string
+// or as an object carrying per-folder settings
+type FolderConfig struct {
+	Path     string `json:"path"`
+	Label    string `json:"label,omitempty"`    // shown in reports instead of the path
+	Pattern  string `json:"pattern,omitempty"`  // overrides --pattern for this folder
+	Expected int    `json:"expected,omitempty"` // expected daily entry count, 0 if unknown
+}
+
+// UnmarshalJSON accepts both "C:\\Logs" and {"path": "C:\\Logs", "expected": 300}
+func (f *FolderConfig) UnmarshalJSON(data []byte) error {
+	var path string
+	if err := json.Unmarshal(data, &path); err == nil {
+		*f = FolderConfig{Path: path}
+		return nil
+	}
+
+	// A custom unmarshaler doesn't inherit the outer decoder's settings, so unknown keys are rejected here too
+	type plainFolderConfig FolderConfig
+	var entry plainFolderConfig
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&entry); err != nil {
+		return fmt.Errorf("folder entry must be a path or an object: %w", err)
+	}
+	*f = FolderConfig(entry)
+	return nil
+}
+
+// JSONAggregate holds the totals across all folders in the --json report
+type JSONAggregate struct {
+	TotalFolders        int            `json:"total_folders"`
+	SuccessfulFolders   int            `json:"successful_folders"`
+	TotalCount          int            `json:"total_count"`
+	LinesScanned        int64          `json:"lines_scanned"`
+	BytesScanned        int64          `json:"bytes_scanned"`
+	SkippedLines        int            `json:"skipped_lines"`
+	DistinctDays        int            `json:"distinct_days"`
+	DistinctRecipients  int            `json:"distinct_recipients"`
+	AveragePerDay       float64        `json:"average_per_day"`
+	MedianPerDay        int            `json:"median_per_day"`
+	P90PerDay           int            `json:"p90_per_day"`
+	DuplicatesCollapsed int            `json:"duplicates_collapsed,omitempty"`
+	OversizedFiles      int            `json:"oversized_files,omitempty"`
+	MissingDates        []string       `json:"missing_dates,omitempty"`
+	Anomalies           []string       `json:"anomalies,omitempty"`
+	Dates               map[string]int `json:"dates"`
+	Cumulative          map[string]int `json:"cumulative,omitempty"` // running total per date, only with --cumulative
+}
+
+// JSONReport is the document written to stdout by --json
+type JSONReport struct {
+	Folders   []analyzer.FolderResult `json:"folders"`
+	Aggregate JSONAggregate           `json:"aggregate"`
+	Baseline  *JSONRunComparison      `json:"baseline,omitempty"` // only with --baseline
+}
+
+// JSONRunComparison compares the current folders with the --baseline set, per date and in total
+type JSONRunComparison struct {
+	Dates []JSONDateComparison `json:"dates"`
+	Total JSONDateComparison   `json:"total"`
+}
+
+// JSONDateComparison is the current vs baseline count of one date, or of the whole run
+type JSONDateComparison struct {
+	Date          string   `json:"date,omitempty"`
+	Current       int      `json:"current"`
+	Baseline      int      `json:"baseline"`
+	Delta         int      `json:"delta"`
+	PercentChange *float64 `json:"percent_change,omitempty"` // left out when the baseline count is 0
+	Status        string   `json:"status,omitempty"`         // "added" or "removed" for dates only one side has
+}
+
+// topDomains is how many recipient domains the aggregate section lists
+const topDomains = 10
+
+// exitSomeFoldersFailed is the exit code when at least one folder could not be processed
+const exitSomeFoldersFailed = 2
+
+// exitBelowThreshold is the exit code when a date's aggregate count is under --min-per-day
+const exitBelowThreshold = 3
+
+// exitAllFoldersFailed is the exit code when no folder could be processed at all
+const exitAllFoldersFailed = 4
+
+// exitBaselineFlagged is the exit code when a folder is outside its expected daily volume
+const exitBaselineFlagged = 5
+
+// exitSelfCheckFailed is the exit code when --self-check finds inconsistent counts
+const exitSelfCheckFailed = 6
+
+// exitInterrupted is the exit code after Ctrl+C, matching the shell convention of 128+SIGINT
+const exitInterrupted = 130
+
+// ReportOptions controls how results are printed
+type ReportOptions struct {
+	Verbose      bool
+	Daypart      bool   // group hours into night/morning/afternoon/evening
+	FilesByCount bool   // list files busiest first instead of by name
+	Precision    int    // decimals for averages, ratios and percentages
+	Denominator  string // "active-days", "calendar-days" or a fixed day count for the daily average
+	Cumulative   bool   // show a running total next to each date's count
+	FillGaps     bool   // list dates without entries as zero so the running total is continuous
+	Stable       bool   // sort every listing and leave out run-specific details, for diffable reports
+	TopDates     int    // list only the N busiest dates in the aggregate, 0 lists all
+	Histogram    bool   // draw a 24-hour bar chart per date in each folder's section
+	Rollup       string // "week" or "month" to list the aggregate by ISO week or month, "" for daily
+	ByWeekday    bool   // total and average the aggregate per day of the week
+	Quiet        bool   // leave out the per-folder section except for folder errors
+	Color        bool   // highlight folder status and totals with ANSI colors (terminal output only)
+	Timings      bool   // list each folder's scan time, slowest first, and total them in the summary
+
+	// AnomalyThreshold tags aggregate dates more than this many standard deviations from the mean, 0 disables
+	AnomalyThreshold float64
+}
+
+// ParquetRow is a single (folder, date, hour) bucket written by --parquet
+type ParquetRow struct {
+	Folder string `parquet:"folder"`
+	Date   string `parquet:"date"`
+	Hour   int32  `parquet:"hour"`
+	Count  int64  `parquet:"count"`
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	// Subcommands are dispatched before option parsing
+	if os.Args[1] == "gen" {
+		if err := runGen(os.Args[2:]); err != nil {
+			fmt.Printf("Error generating fixtures: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var folderPaths []string
+	folderConfigs := make(map[string]FolderConfig)
+
+	// Warnings about the folder list come up before --warnings-json (which may follow them on the
+	// command line) has been read. They are recorded and passed on once the logger is set up.
+	var startupWarnings bytes.Buffer
+	startupLog := analyzer.NewWarningLogger(&startupWarnings, true)
+	report := ReportOptions{Precision: 2, Denominator: "active-days"}
+	baselineTolerance := 50.0
+	var baselineInputs []string
+	parquetPath := ""
+	csvPath := ""
+	htmlPath := ""
+	csvByFolder := false
+	flagAnomalies := false
+	stddevThreshold, stddevGiven := 2.0, false
+	esBulkPath := ""
+	esBulkMode := "folder-date"
+	esIndex := "mailchecker-2fa"
+	onlyFolder := ""
+	warningsJSON := false
+	selfCheck := false
+	scheduleSpec := ""
+	listOnly := false
+	noColor := os.Getenv("NO_COLOR") != ""
+	dryRun := false
+	follow := false
+	cachePath := ""
+	warningsPath := ""
+	patternGiven := false
+	format := "text"
+	outputPath := ""
+	minPerDay := 0
+	opts := analyzer.Options{
+		Patterns:       []string{analyzer.DefaultPattern},
+		FolderPatterns: make(map[string]string),
+		ErrorSample:    100,
+		MaxLineBytes:   analyzer.DefaultMaxLineBytes,
+		ScheduleWindow: 5 * time.Minute,
+		Retries:        2,
+		RetryDelay:     200 * time.Millisecond,
+	}
+	var extensionsFlag []string
+	var configOptions Config // options from the config file(s), applied after the command line is parsed
+
+	// Parse command line arguments
+	for i := 1; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		if arg == "--verbose" {
+			report.Verbose = true
+		} else if arg == "--quiet" {
+			report.Quiet = true
+		} else if arg == "--no-color" {
+			noColor = true
+		} else if arg == "--files-by-count" {
+			report.FilesByCount = true
+		} else if arg == "--precision" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --precision flag requires a number of decimals")
+				os.Exit(1)
+			}
+			if _, err := fmt.Sscanf(os.Args[i+1], "%d", &report.Precision); err != nil || report.Precision < 0 || report.Precision > 10 {
+				fmt.Printf("Error: invalid --precision value %q (expected 0-10)\n", os.Args[i+1])
+				os.Exit(1)
+			}
+			i++ // Skip next argument (decimals)
+		} else if arg == "--by-minute-of-hour" {
+			opts.ByMinuteOfHour = true
+		} else if arg == "--exclude-hours" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --exclude-hours flag requires a comma-separated list of hours (0-23)")
+				os.Exit(1)
+			}
+			for _, field := range strings.Split(os.Args[i+1], ",") {
+				var hour int
+				if _, err := fmt.Sscanf(strings.TrimSpace(field), "%d", &hour); err != nil || hour < 0 || hour > 23 {
+					fmt.Printf("Error: invalid --exclude-hours value %q\n", os.Args[i+1])
+					os.Exit(1)
+				}
+				opts.ExcludeHours[hour] = true
+			}
+			i++ // Skip next argument (hours)
+		} else if arg == "--granularity" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --granularity flag requires hour or minute")
+				os.Exit(1)
+			}
+			switch os.Args[i+1] {
+			case "hour":
+				opts.MinuteBuckets = false
+			case "minute":
+				opts.MinuteBuckets = true
+			default:
+				fmt.Printf("Error: invalid --granularity value %q (use hour or minute)\n", os.Args[i+1])
+				os.Exit(1)
+			}
+			i++ // Skip next argument (granularity)
+		} else if arg == "--denominator" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --denominator flag requires active-days, calendar-days or a number of days")
+				os.Exit(1)
+			}
+			report.Denominator = os.Args[i+1]
+			if report.Denominator != "active-days" && report.Denominator != "calendar-days" {
+				var days int
+				if _, err := fmt.Sscanf(report.Denominator, "%d", &days); err != nil || days < 1 {
+					fmt.Printf("Error: invalid --denominator %q (expected active-days, calendar-days or a number of days)\n", report.Denominator)
+					os.Exit(1)
+				}
+			}
+			i++ // Skip next argument (denominator)
+		} else if arg == "--cumulative" {
+			report.Cumulative = true
+		} else if arg == "--fill-gaps" {
+			report.FillGaps = true
+		} else if arg == "--daypart" {
+			report.Daypart = true
+		} else if arg == "--timings" {
+			report.Timings = true
+		} else if arg == "--stable" {
+			report.Stable = true
+			opts.Progress = false
+			opts.Sequential = true // concurrent folders would interleave warnings differently each run
+		} else if arg == "--max-matches-per-file" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --max-matches-per-file flag requires a number")
+				os.Exit(1)
+			}
+			if _, err := fmt.Sscanf(os.Args[i+1], "%d", &opts.MaxMatches); err != nil || opts.MaxMatches < 1 {
+				fmt.Printf("Error: invalid --max-matches-per-file value %q\n", os.Args[i+1])
+				os.Exit(1)
+			}
+			i++ // Skip next argument (match limit)
+		} else if arg == "--max-line-bytes" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --max-line-bytes flag requires a number of bytes")
+				os.Exit(1)
+			}
+			if _, err := fmt.Sscanf(os.Args[i+1], "%d", &opts.MaxLineBytes); err != nil || opts.MaxLineBytes < 1 {
+				fmt.Printf("Error: invalid --max-line-bytes value %q\n", os.Args[i+1])
+				os.Exit(1)
+			}
+			i++ // Skip next argument (line length)
+		} else if arg == "--max-file-size" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --max-file-size flag requires a size")
+				os.Exit(1)
+			}
+			size, err := parseByteSize(os.Args[i+1])
+			if err != nil || size < 1 {
+				fmt.Printf("Error: invalid --max-file-size value %q (expected a size like 500MB)\n", os.Args[i+1])
+				os.Exit(1)
+			}
+			opts.MaxFileSize = size
+			i++ // Skip next argument (file size)
+		} else if arg == "--check-overlap" {
+			opts.CheckOverlap = true
+		} else if arg == "--hourly-top-k" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --hourly-top-k flag requires a number of dates")
+				os.Exit(1)
+			}
+			if _, err := fmt.Sscanf(os.Args[i+1], "%d", &opts.HourlyTopK); err != nil || opts.HourlyTopK < 1 {
+				fmt.Printf("Error: invalid --hourly-top-k value %q\n", os.Args[i+1])
+				os.Exit(1)
+			}
+			i++ // Skip next argument (number of dates)
+		} else if arg == "--max-error-rate" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --max-error-rate flag requires a fraction between 0 and 1")
+				os.Exit(1)
+			}
+			if _, err := fmt.Sscanf(os.Args[i+1], "%g", &opts.MaxErrorRate); err != nil || opts.MaxErrorRate <= 0 || opts.MaxErrorRate > 1 {
+				fmt.Printf("Error: invalid --max-error-rate value %q (expected a fraction above 0 and up to 1)\n", os.Args[i+1])
+				os.Exit(1)
+			}
+			i++ // Skip next argument (error rate)
+		} else if arg == "--error-sample" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --error-sample flag requires a number of lines")
+				os.Exit(1)
+			}
+			if _, err := fmt.Sscanf(os.Args[i+1], "%d", &opts.ErrorSample); err != nil || opts.ErrorSample < 1 {
+				fmt.Printf("Error: invalid --error-sample value %q\n", os.Args[i+1])
+				os.Exit(1)
+			}
+			i++ // Skip next argument (sample size)
+		} else if arg == "--line-prefix" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --line-prefix flag requires a prefix")
+				os.Exit(1)
+			}
+			opts.LinePrefix = os.Args[i+1]
+			i++ // Skip next argument (prefix)
+		} else if arg == "--schedule" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --schedule flag requires a cron expression")
+				os.Exit(1)
+			}
+			schedule, err := cron.ParseStandard(os.Args[i+1])
+			if err != nil {
+				fmt.Printf("Error: invalid --schedule: %v\n", err)
+				os.Exit(1)
+			}
+			scheduleSpec = os.Args[i+1]
+			opts.Schedule = schedule
+			i++ // Skip next argument (cron expression)
+		} else if arg == "--folder-timeout" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --folder-timeout flag requires a duration")
+				os.Exit(1)
+			}
+			timeout, err := time.ParseDuration(os.Args[i+1])
+			if err != nil || timeout <= 0 {
+				fmt.Printf("Error: invalid --folder-timeout value %q (expected a duration like 30s)\n", os.Args[i+1])
+				os.Exit(1)
+			}
+			opts.FolderTimeout = timeout
+			i++ // Skip next argument (duration)
+		} else if arg == "--retries" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --retries flag requires a number")
+				os.Exit(1)
+			}
+			if _, err := fmt.Sscanf(os.Args[i+1], "%d", &opts.Retries); err != nil || opts.Retries < 0 {
+				fmt.Printf("Error: invalid --retries value %q\n", os.Args[i+1])
+				os.Exit(1)
+			}
+			i++ // Skip next argument (retry count)
+		} else if arg == "--retry-delay" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --retry-delay flag requires a duration")
+				os.Exit(1)
+			}
+			delay, err := time.ParseDuration(os.Args[i+1])
+			if err != nil || delay < 0 {
+				fmt.Printf("Error: invalid --retry-delay value %q (expected a duration like 500ms)\n", os.Args[i+1])
+				os.Exit(1)
+			}
+			opts.RetryDelay = delay
+			i++ // Skip next argument (duration)
+		} else if arg == "--schedule-tolerance" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --schedule-tolerance flag requires a duration")
+				os.Exit(1)
+			}
+			window, err := time.ParseDuration(os.Args[i+1])
+			if err != nil || window < 0 {
+				fmt.Printf("Error: invalid --schedule-tolerance value %q (expected a duration like 10m)\n", os.Args[i+1])
+				os.Exit(1)
+			}
+			opts.ScheduleWindow = window
+			i++ // Skip next argument (duration)
+		} else if arg == "--ext" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --ext flag requires a comma-separated list of extensions")
+				os.Exit(1)
+			}
+			extensionsFlag = append(extensionsFlag, strings.Split(os.Args[i+1], ",")...)
+			i++ // Skip next argument (extensions)
+		} else if arg == "--include" || arg == "--exclude" {
+			if i+1 >= len(os.Args) {
+				fmt.Printf("Error: %s flag requires a file name pattern\n", arg)
+				os.Exit(1)
+			}
+			pattern := os.Args[i+1]
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				fmt.Printf("Error: invalid %s pattern %q: %v\n", arg, pattern, err)
+				os.Exit(1)
+			}
+			if arg == "--include" {
+				opts.Include = append(opts.Include, pattern)
+			} else {
+				opts.Exclude = append(opts.Exclude, pattern)
+			}
+			i++ // Skip next argument (pattern)
+		} else if arg == "--recursive" {
+			opts.Recursive = true
+		} else if arg == "--follow-symlinks" {
+			opts.FollowSymlinks = true
+		} else if arg == "--output" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --output flag requires a file path")
+				os.Exit(1)
+			}
+			outputPath = os.Args[i+1]
+			i++ // Skip next argument (output file path)
+		} else if arg == "--json" {
+			format = "json"
+		} else if arg == "--ndjson" {
+			format = "ndjson"
+		} else if arg == "--format" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --format flag requires text, markdown, json or ndjson")
+				os.Exit(1)
+			}
+			format = os.Args[i+1]
+			if format != "text" && format != "markdown" && format != "json" && format != "ndjson" {
+				fmt.Printf("Error: invalid --format value %q (use text, markdown, json or ndjson)\n", format)
+				os.Exit(1)
+			}
+			i++ // Skip next argument (report format)
+		} else if arg == "--timestamp-layout" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --timestamp-layout flag requires a Go time layout")
+				os.Exit(1)
+			}
+			layout := os.Args[i+1]
+			if !strings.Contains(layout, "2006") || !strings.Contains(layout, "15") {
+				fmt.Println("Error: --timestamp-layout must contain a year (2006) and a 24-hour clock hour (15)")
+				os.Exit(1)
+			}
+			opts.TimestampLayout = layout
+			i++ // Skip next argument (layout)
+		} else if arg == "--tz" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --tz flag requires a time zone name")
+				os.Exit(1)
+			}
+			location, err := time.LoadLocation(os.Args[i+1])
+			if err != nil {
+				fmt.Printf("Error: invalid --tz %q: %v\n", os.Args[i+1], err)
+				os.Exit(1)
+			}
+			opts.Location = location
+			i++ // Skip next argument (time zone)
+		} else if arg == "--since" || arg == "--until" {
+			if i+1 >= len(os.Args) {
+				fmt.Printf("Error: %s flag requires a date (YYYY-MM-DD)\n", arg)
+				os.Exit(1)
+			}
+			date, err := time.Parse("2006-01-02", os.Args[i+1])
+			if err != nil {
+				fmt.Printf("Error: invalid %s date %q, expected YYYY-MM-DD\n", arg, os.Args[i+1])
+				os.Exit(1)
+			}
+			if arg == "--since" {
+				opts.Since = date
+			} else {
+				opts.Until = date
+			}
+			i++ // Skip next argument (date)
+		} else if arg == "--workers" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --workers flag requires a number")
+				os.Exit(1)
+			}
+			if _, err := fmt.Sscanf(os.Args[i+1], "%d", &opts.Workers); err != nil || opts.Workers < 1 {
+				fmt.Printf("Error: invalid --workers value %q\n", os.Args[i+1])
+				os.Exit(1)
+			}
+			i++ // Skip next argument (worker count)
+		} else if arg == "--cache" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --cache flag requires a file path")
+				os.Exit(1)
+			}
+			cachePath = os.Args[i+1]
+			i++ // Skip next argument (cache file path)
+		} else if arg == "--follow" {
+			follow = true
+		} else if arg == "--progress" {
+			opts.Progress = !report.Stable
+		} else if arg == "--file-workers" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --file-workers flag requires a number")
+				os.Exit(1)
+			}
+			if _, err := fmt.Sscanf(os.Args[i+1], "%d", &opts.FileWorkers); err != nil || opts.FileWorkers < 1 {
+				fmt.Printf("Error: invalid --file-workers value %q\n", os.Args[i+1])
+				os.Exit(1)
+			}
+			i++ // Skip next argument (worker count)
+		} else if arg == "--sequential" {
+			opts.Sequential = true
+		} else if arg == "--strict" {
+			opts.Strict = true
+		} else if arg == "--approx-distinct" {
+			opts.ApproxDistinct = true
+		} else if arg == "--config" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --config flag requires a file path")
+				os.Exit(1)
+			}
+			configPath := os.Args[i+1]
+			config, err := loadConfigFile(configPath, startupLog)
+			if err != nil {
+				fmt.Printf("Error loading config file: %v\n", err)
+				os.Exit(1)
+			}
+			configOptions.mergeOptions(config)
+			for _, folder := range config.Folders {
+				// A glob entry's settings apply to every folder it matches, but one label
+				// can't name several folders
+				matches := expandFolderPattern(folder.Path, startupLog)
+				for _, match := range matches {
+					entry := folder
+					entry.Path = match
+					if len(matches) > 1 {
+						entry.Label = ""
+					}
+					folderPaths = append(folderPaths, entry.Path)
+					if earlier, ok := folderConfigs[entry.Path]; ok {
+						// Listed by an earlier config too; its settings come first
+						earlier.fillFrom(entry)
+						entry = earlier
+					}
+					folderConfigs[entry.Path] = entry
+					if entry.Pattern != "" {
+						opts.FolderPatterns[entry.Path] = entry.Pattern
+					}
+				}
+			}
+			i++ // Skip next argument (config file path)
+		} else if arg == "--baseline" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --baseline flag requires a folder path, glob or config file")
+				os.Exit(1)
+			}
+			baselineInputs = append(baselineInputs, os.Args[i+1])
+			i++ // Skip next argument (baseline folder or config)
+		} else if arg == "--baseline-tolerance" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --baseline-tolerance flag requires a percentage")
+				os.Exit(1)
+			}
+			if _, err := fmt.Sscanf(os.Args[i+1], "%g", &baselineTolerance); err != nil || baselineTolerance < 0 {
+				fmt.Printf("Error: invalid --baseline-tolerance value %q\n", os.Args[i+1])
+				os.Exit(1)
+			}
+			i++ // Skip next argument (tolerance)
+		} else if arg == "--rollup" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --rollup flag requires week or month")
+				os.Exit(1)
+			}
+			switch os.Args[i+1] {
+			case "day":
+				report.Rollup = ""
+			case "week", "month":
+				report.Rollup = os.Args[i+1]
+			default:
+				fmt.Printf("Error: invalid --rollup %q (expected day, week or month)\n", os.Args[i+1])
+				os.Exit(1)
+			}
+			i++ // Skip next argument (period)
+		} else if arg == "--by-weekday" {
+			report.ByWeekday = true
+		} else if arg == "--histogram" {
+			report.Histogram = true
+		} else if arg == "--min-per-day" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --min-per-day flag requires a number of entries")
+				os.Exit(1)
+			}
+			if _, err := fmt.Sscanf(os.Args[i+1], "%d", &minPerDay); err != nil || minPerDay < 1 {
+				fmt.Printf("Error: invalid --min-per-day value %q\n", os.Args[i+1])
+				os.Exit(1)
+			}
+			i++ // Skip next argument (minimum entries per day)
+		} else if arg == "--top" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --top flag requires a number of dates")
+				os.Exit(1)
+			}
+			if _, err := fmt.Sscanf(os.Args[i+1], "%d", &report.TopDates); err != nil || report.TopDates < 1 {
+				fmt.Printf("Error: invalid --top value %q\n", os.Args[i+1])
+				os.Exit(1)
+			}
+			i++ // Skip next argument (number of dates)
+		} else if arg == "--flag-anomalies" {
+			flagAnomalies = true
+		} else if arg == "--stddev-threshold" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --stddev-threshold flag requires a number of standard deviations")
+				os.Exit(1)
+			}
+			if _, err := fmt.Sscanf(os.Args[i+1], "%g", &stddevThreshold); err != nil || stddevThreshold <= 0 {
+				fmt.Printf("Error: invalid --stddev-threshold value %q\n", os.Args[i+1])
+				os.Exit(1)
+			}
+			stddevGiven = true
+			i++ // Skip next argument (threshold)
+		} else if arg == "--ignore-case" {
+			opts.IgnoreCase = true
+		} else if arg == "--pattern" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --pattern flag requires the text to match")
+				os.Exit(1)
+			}
+			if os.Args[i+1] == "" {
+				fmt.Println("Error: --pattern must not be empty")
+				os.Exit(1)
+			}
+			if opts.Regex != nil {
+				fmt.Println("Error: --pattern and --regex cannot be combined")
+				os.Exit(1)
+			}
+			// Repeated --pattern flags are counted together in one pass, each also on its own
+			if !patternGiven {
+				opts.Patterns = nil
+			}
+			if !slices.Contains(opts.Patterns, os.Args[i+1]) {
+				opts.Patterns = append(opts.Patterns, os.Args[i+1])
+			}
+			patternGiven = true
+			i++ // Skip next argument (pattern)
+		} else if arg == "--regex" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --regex flag requires a regular expression")
+				os.Exit(1)
+			}
+			if patternGiven {
+				fmt.Println("Error: --pattern and --regex cannot be combined")
+				os.Exit(1)
+			}
+			regex, err := regexp.Compile(os.Args[i+1])
+			if err != nil {
+				fmt.Printf("Error: invalid --regex: %v\n", err)
+				os.Exit(1)
+			}
+			opts.Regex = regex
+			i++ // Skip next argument (regular expression)
+		} else if arg == "--csv" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --csv flag requires a file path")
+				os.Exit(1)
+			}
+			csvPath = os.Args[i+1]
+			i++ // Skip next argument (CSV file path)
+		} else if arg == "--html" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --html flag requires a file path")
+				os.Exit(1)
+			}
+			htmlPath = os.Args[i+1]
+			i++ // Skip next argument (HTML file path)
+		} else if arg == "--csv-by-folder" {
+			csvByFolder = true
+		} else if arg == "--parquet" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --parquet flag requires a file path")
+				os.Exit(1)
+			}
+			parquetPath = os.Args[i+1]
+			i++ // Skip next argument (parquet file path)
+		} else if arg == "--id-regex" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --id-regex flag requires a regular expression")
+				os.Exit(1)
+			}
+			idRegex, err := regexp.Compile(os.Args[i+1])
+			if err != nil {
+				fmt.Printf("Error: invalid --id-regex: %v\n", err)
+				os.Exit(1)
+			}
+			if idRegex.NumSubexp() < 1 {
+				fmt.Println("Error: --id-regex must contain a capture group for the event ID")
+				os.Exit(1)
+			}
+			opts.IDRegex = idRegex
+			i++ // Skip next argument (regular expression)
+		} else if arg == "--result-field" || arg == "--result-regex" {
+			if i+1 >= len(os.Args) {
+				fmt.Printf("Error: %s flag requires a value\n", arg)
+				os.Exit(1)
+			}
+			if opts.ResultRegex != nil {
+				fmt.Println("Error: --result-field and --result-regex cannot be combined")
+				os.Exit(1)
+			}
+			expr := os.Args[i+1]
+			if arg == "--result-field" {
+				// A field name matches key=value pairs such as "result=denied"
+				expr = `\b` + regexp.QuoteMeta(expr) + `=([^\s,;]+)`
+			}
+			resultRegex, err := regexp.Compile(expr)
+			if err != nil {
+				fmt.Printf("Error: invalid %s: %v\n", arg, err)
+				os.Exit(1)
+			}
+			if resultRegex.NumSubexp() < 1 {
+				fmt.Println("Error: --result-regex must contain a capture group for the result code")
+				os.Exit(1)
+			}
+			opts.ResultRegex = resultRegex
+			i++ // Skip next argument (field name or regular expression)
+		} else if arg == "--client-regex" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --client-regex flag requires a regular expression")
+				os.Exit(1)
+			}
+			clientRegex, err := regexp.Compile(os.Args[i+1])
+			if err != nil {
+				fmt.Printf("Error: invalid --client-regex: %v\n", err)
+				os.Exit(1)
+			}
+			if clientRegex.NumSubexp() < 1 {
+				fmt.Println("Error: --client-regex must contain a capture group for the client")
+				os.Exit(1)
+			}
+			opts.ClientRegex = clientRegex
+			i++ // Skip next argument (regular expression)
+		} else if arg == "--self-check" {
+			selfCheck = true
+		} else if arg == "--list-files" {
+			listOnly = true
+		} else if arg == "--dry-run" {
+			dryRun = true
+		} else if arg == "--warnings-json" {
+			warningsJSON = true
+		} else if arg == "--warnings-file" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --warnings-file flag requires a file path")
+				os.Exit(1)
+			}
+			warningsJSON = true
+			warningsPath = os.Args[i+1]
+			i++ // Skip next argument (warnings file path)
+		} else if arg == "--es-bulk" || arg == "--es-bulk-mode" || arg == "--es-index" {
+			if i+1 >= len(os.Args) {
+				fmt.Printf("Error: %s flag requires a value\n", arg)
+				os.Exit(1)
+			}
+			switch arg {
+			case "--es-bulk":
+				esBulkPath = os.Args[i+1]
+			case "--es-bulk-mode":
+				esBulkMode = os.Args[i+1]
+				if esBulkMode != "folder-date" && esBulkMode != "line" {
+					fmt.Printf("Error: invalid --es-bulk-mode %q (expected folder-date or line)\n", esBulkMode)
+					os.Exit(1)
+				}
+			case "--es-index":
+				esIndex = os.Args[i+1]
+			}
+			i++ // Skip next argument (flag value)
+		} else if arg == "--only-folder" {
+			if i+1 >= len(os.Args) {
+				fmt.Println("Error: --only-folder flag requires a folder path")
+				os.Exit(1)
+			}
+			onlyFolder = os.Args[i+1]
+			i++ // Skip next argument (folder path)
+		} else if !strings.HasPrefix(arg, "--") {
+			// It's a folder path, or a glob pattern for several
+			folderPaths = append(folderPaths, expandFolderPattern(expandPath(arg), startupLog)...)
+		}
+	}
+
+	opts.CollectEntries = esBulkPath != "" && esBulkMode == "line"
+
+	// Calendar-day denominators and gap filling only make sense for daily listings
+	if report.Rollup != "" && (report.Denominator != "active-days" || report.FillGaps) {
+		fmt.Println("Error: --rollup cannot be combined with --denominator or --fill-gaps")
+		os.Exit(1)
+	}
+
+	jsonOutput, markdownOutput, ndjsonOutput := format == "json", format == "markdown", format == "ndjson"
+
+	// NDJSON holds only the counted lines, so there is nowhere to put the comparison
+	if len(baselineInputs) > 0 && ndjsonOutput {
+		fmt.Println("Error: --baseline cannot be combined with --ndjson; use --json or --format markdown for the comparison")
+		os.Exit(1)
+	}
+
+	// Colors are for people at a terminal; files, pipes, --json and Markdown get plain text
+	report.Color = !noColor && format == "text" && outputPath == "" && isTerminal(os.Stdout)
+
+	if report.Quiet && report.Verbose {
+		fmt.Println("Error: --quiet and --verbose cannot be combined")
+		os.Exit(1)
+	}
+
+	if report.TopDates > 0 && report.Cumulative {
+		fmt.Println("Error: --top cannot be combined with --cumulative, which needs every date in order")
+		os.Exit(1)
+	}
+
+	if stddevGiven && !flagAnomalies {
+		fmt.Println("Error: --stddev-threshold requires --flag-anomalies")
+		os.Exit(1)
+	}
+	if flagAnomalies {
+		if report.Cumulative {
+			fmt.Println("Error: --flag-anomalies cannot be combined with --cumulative")
+			os.Exit(1)
+		}
+		report.AnomalyThreshold = stddevThreshold
+	}
+
+	// Config options fill in what the command line left unset. The dates were validated when the
+	// config was loaded.
+	if configOptions.Pattern != "" && !patternGiven && opts.Regex == nil {
+		opts.Patterns = []string{configOptions.Pattern}
+	}
+	if configOptions.Workers != 0 && opts.Workers == 0 {
+		opts.Workers = configOptions.Workers
+	}
+	if configOptions.Recursive {
+		opts.Recursive = true
+	}
+	if configOptions.Since != "" && opts.Since.IsZero() {
+		opts.Since, _ = time.Parse("2006-01-02", configOptions.Since)
+	}
+	if configOptions.Until != "" && opts.Until.IsZero() {
+		opts.Until, _ = time.Parse("2006-01-02", configOptions.Until)
+	}
+
+	if !opts.Since.IsZero() && !opts.Until.IsZero() && opts.Until.Before(opts.Since) {
+		fmt.Println("Error: --until must not be before --since")
+		os.Exit(1)
+	}
+
+	// --ext wins over the config's extensions; plain .txt remains the default
+	switch {
+	case len(extensionsFlag) > 0:
+		opts.Extensions = analyzer.NormalizeExtensions(extensionsFlag)
+	case len(configOptions.Extensions) > 0:
+		opts.Extensions = analyzer.NormalizeExtensions(configOptions.Extensions)
+	}
+	if len(opts.Extensions) == 0 {
+		opts.Extensions = []string{"txt"}
+	}
+
+	// --ignore-case may come before or after --regex, so the expression is only case-folded here
+	if opts.IgnoreCase && opts.Regex != nil {
+		opts.Regex = regexp.MustCompile("(?i)" + opts.Regex.String())
+	}
+
+	// The same folder named twice (literally, in several configs or via a glob) would be counted
+	// twice. The first appearance is kept, and takes any settings it lacks from the duplicates.
+	var duplicates map[string]string
+	folderPaths, duplicates = dedupeFolderPaths(folderPaths, startupLog)
+	for _, duplicate := range analyzer.SortedKeys(duplicates) {
+		kept := duplicates[duplicate]
+		if config, ok := folderConfigs[duplicate]; ok && duplicate != kept {
+			merged := folderConfigs[kept]
+			merged.Path = kept
+			merged.fillFrom(config)
+			folderConfigs[kept] = merged
+			if merged.Pattern != "" {
+				opts.FolderPatterns[kept] = merged.Pattern
+			}
+		}
+	}
+
+	if len(folderPaths) == 0 {
+		fmt.Println("Error: No folder paths provided")
+		printUsage()
+		os.Exit(1)
+	}
+
+	var baselinePaths []string
+	if len(baselineInputs) > 0 {
+		baselinePaths = resolveBaselinePaths(baselineInputs, opts.FolderPatterns, startupLog)
+		if len(baselinePaths) == 0 {
+			fmt.Println("Error: --baseline did not match any folders")
+			os.Exit(1)
+		}
+	}
+
+	// --dry-run shows the same file set per folder, for a person checking the settings
+	if dryRun {
+		opts.Logger = analyzer.NewWarningLogger(os.Stderr, warningsJSON)
+		replayWarnings(&startupWarnings, opts.Logger)
+		printDryRun(folderPaths, opts)
+		return
+	}
+
+	// --list-files only resolves the file set; stdout carries nothing but the paths
+	if listOnly {
+		opts.Logger = analyzer.NewWarningLogger(os.Stderr, warningsJSON)
+		replayWarnings(&startupWarnings, opts.Logger)
+		listFiles(folderPaths, opts)
+		return
+	}
+
+	// The report goes to stdout unless --output names a file
+	var out io.Writer = os.Stdout
+	if outputPath != "" {
+		outputFile, err := os.Create(outputPath)
+		if err != nil {
+			fmt.Printf("Error creating output file: %v\n", err)
+			os.Exit(1)
+		}
+		defer outputFile.Close()
+		out = outputFile
+	}
+
+	// Warnings stay on stdout as text unless structured diagnostics were requested;
+	// --json and --output keep the report free of them
+	var warningsOut io.Writer = os.Stdout
+	if jsonOutput || markdownOutput || ndjsonOutput || outputPath != "" || warningsJSON {
+		warningsOut = os.Stderr
+	}
+	if warningsPath != "" && warningsJSON {
+		warningsFile, err := os.Create(warningsPath)
+		if err != nil {
+			fmt.Printf("Error creating warnings file: %v\n", err)
+			os.Exit(1)
+		}
+		defer warningsFile.Close()
+		warningsOut = warningsFile
+	}
+	opts.Logger = analyzer.NewWarningLogger(warningsOut, warningsJSON)
+	opts.Status = os.Stderr
+	replayWarnings(&startupWarnings, opts.Logger)
+
+	// Cross-file de-duplication and collected or streamed lines can't be rebuilt from per-file results
+	if cachePath != "" {
+		if opts.IDRegex != nil || opts.CollectEntries || ndjsonOutput {
+			opts.Logger.Warnf("", cachePath, "--cache is not used with --id-regex, --es-bulk-mode line or --ndjson")
+		} else {
+			var err error
+			opts.Cache, err = analyzer.LoadCache(cachePath, analyzer.ScanFingerprint(opts, scheduleSpec))
+			if err != nil {
+				opts.Logger.Warnf("", cachePath, "%v", err)
+			}
+		}
+	}
+
+	if format == "text" && !report.Quiet {
+		fmt.Fprintf(out, "Analyzing %d folder(s)...\n", len(folderPaths))
+	}
+
+	// Ctrl+C stops in-flight scans; folders finished by then are still reported.
+	// Once cancelled, a second Ctrl+C kills the process as usual.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	// The baseline set is counted first, so that --follow can keep running on the current one
+	var baselineDateCountMap map[string]int
+	if len(baselinePaths) > 0 {
+		baselineDateCountMap = make(map[string]int)
+		for _, result := range analyzer.ProcessFolders(ctx, baselinePaths, opts) {
+			if result.Error != nil {
+				opts.Logger.Warnf(result.FolderPath, "", "baseline folder %s: %v", result.FolderPath, result.Error)
+				continue
+			}
+			analyzer.MergeCounts(baselineDateCountMap, result.DateCountMap)
+		}
+	}
+
+	// Stream counted lines as they are found; baseline lines are not part of the stream
+	var entryWriter *ndjsonWriter
+	if ndjsonOutput {
+		entryWriter = &ndjsonWriter{out: out, folderConfigs: folderConfigs}
+		opts.OnEntry = entryWriter.writeEntry
+	}
+
+	// Process folders concurrently, or keep following them until Ctrl+C
+	startTime := time.Now()
+	var results []analyzer.FolderResult
+	if follow {
+		fmt.Fprintf(os.Stderr, "Following %d folder(s), checking every %s; press Ctrl+C to stop and print the report\n", len(folderPaths), analyzer.FollowInterval)
+		results = analyzer.Follow(ctx, folderPaths, opts)
+	} else {
+		results = analyzer.ProcessFolders(ctx, folderPaths, opts)
+	}
+	elapsed := time.Since(startTime)
+
+	if opts.Cache != nil {
+		if err := opts.Cache.Save(); err != nil {
+			opts.Logger.Warnf("", cachePath, "could not save cache %s: %v", cachePath, err)
+		}
+	}
+
+	// Ctrl+C is how --follow normally ends, so its results are complete rather than partial
+	interrupted := ctx.Err() != nil && !follow
+	if interrupted {
+		fmt.Fprintln(os.Stderr, "Interrupted: reporting partial results, unfinished folders are marked cancelled")
+	}
+	for i := range results {
+		results[i].Label = folderConfigs[results[i].FolderPath].Label
+	}
+
+	// Folder errors are part of the text report, but JSON diagnostics must carry them too
+	if warningsJSON {
+		for _, result := range results {
+			if result.Error != nil {
+				opts.Logger.Errorf(result.FolderPath, "", "%v", result.Error)
+			}
+		}
+	}
+
+	// Write Elasticsearch bulk actions if requested
+	if esBulkPath != "" {
+		if err := writeESBulkFile(esBulkPath, results, esBulkMode, esIndex); err != nil {
+			fmt.Printf("Error writing Elasticsearch bulk file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Write hourly rows for the data lake if requested
+	if parquetPath != "" {
+		if err := writeParquetFile(parquetPath, results); err != nil {
+			fmt.Printf("Error writing parquet file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Write date counts for spreadsheets if requested
+	if csvPath != "" {
+		if err := writeCSVFile(csvPath, results, csvByFolder, report.Cumulative, report.FillGaps); err != nil {
+			fmt.Printf("Error writing CSV file: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Write a self-contained chart and table for sharing if requested
+	if htmlPath != "" {
+		if err := writeHTMLFile(htmlPath, results, analyzer.DescribeMatch(opts.Patterns, opts.Regex, false)); err != nil {
+			fmt.Printf("Error writing HTML report: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	// Aggregate results
+	aggregateDateCountMap := make(map[string]int)
+	aggregateRecipientSketch := make(map[string]*analyzer.HyperLogLog)
+	aggregateResultCounts := make(map[string]map[string]int)
+	aggregateClientCountMap := make(map[string]int)
+	aggregatePatternDateCounts := make(map[string]map[string]int)
+	aggregateRecipients := make(map[string]bool)
+	aggregateDomainCountMap := make(map[string]int)
+	var aggregateMinuteCounts [60]int
+	totalEntriesAcrossAllFolders := 0
+	totalDuplicatesCollapsed := 0
+	totalSkipped := 0
+	totalOversized := 0
+	var totalLines, totalBytes int64
+	totalScheduleAligned, totalScheduleOff := 0, 0
+	successfulFolders := 0
+
+	if format == "text" && !report.Quiet {
+		fmt.Fprintln(out, "\n"+strings.Repeat("=", 80))
+		fmt.Fprintln(out, "RESULTS BY FOLDER")
+		fmt.Fprintln(out, strings.Repeat("=", 80))
+	}
+
+	onlyFolderMatched := false
+	for _, result := range results {
+		// Failed folders were still (partly) read, so they count toward the input scanned
+		totalLines += result.LinesScanned
+		totalBytes += result.BytesScanned
+		totalOversized += len(result.OversizedFiles)
+
+		// With --only-folder every folder still feeds the aggregate, but only the match is printed
+		showDetails := onlyFolder == "" || result.Matches(onlyFolder)
+		if showDetails {
+			onlyFolderMatched = true
+			// Quiet runs still need to see which folders failed
+			if format == "text" && (!report.Quiet || result.Error != nil) {
+				printFolderResult(out, result, report)
+			}
+		}
+
+		if result.Error != nil {
+			continue
+		}
+
+		successfulFolders++
+		totalEntriesAcrossAllFolders += result.TotalCount
+		totalDuplicatesCollapsed += result.DuplicateCount
+		totalSkipped += result.SkippedCount
+		totalScheduleAligned += result.ScheduleAligned
+		totalScheduleOff += result.ScheduleOff
+
+		// Aggregate dates
+		for date, count := range result.DateCountMap {
+			aggregateDateCountMap[date] += count
+		}
+
+		for minute, count := range result.MinuteOfHourCounts {
+			aggregateMinuteCounts[minute] += count
+		}
+
+		for client, count := range result.ClientCountMap {
+			aggregateClientCountMap[client] += count
+		}
+
+		for pattern, dates := range result.PatternDateCounts {
+			if aggregatePatternDateCounts[pattern] == nil {
+				aggregatePatternDateCounts[pattern] = make(map[string]int)
+			}
+			analyzer.MergeCounts(aggregatePatternDateCounts[pattern], dates)
+		}
+
+		for email := range result.Recipients {
+			aggregateRecipients[email] = true
+		}
+
+		for domain, count := range result.DomainCountMap {
+			aggregateDomainCountMap[domain] += count
+		}
+
+		for date, buckets := range result.DateResultCounts {
+			if aggregateResultCounts[date] == nil {
+				aggregateResultCounts[date] = make(map[string]int)
+			}
+			for bucket, count := range buckets {
+				aggregateResultCounts[date][bucket] += count
+			}
+		}
+
+		// Merge recipient sketches so the aggregate estimate counts each recipient once
+		for date, sketch := range result.DateRecipientSketch {
+			if aggregateRecipientSketch[date] == nil {
+				aggregateRecipientSketch[date] = analyzer.NewHyperLogLog()
+			}
+			aggregateRecipientSketch[date].Merge(sketch)
+		}
+	}
+
+	// --approx-distinct keeps no exact recipient sets, so the total comes from the merged sketches
+	distinctRecipients := len(aggregateRecipients)
+	recipientsLabel := strconv.Itoa(distinctRecipients)
+	if opts.ApproxDistinct {
+		overall := analyzer.NewHyperLogLog()
+		for _, sketch := range aggregateRecipientSketch {
+			overall.Merge(sketch)
+		}
+		distinctRecipients = int(overall.Estimate())
+		recipientsLabel = fmt.Sprintf("~%d (estimated)", distinctRecipients)
+	}
+
+	exitCode := 0
+	switch {
+	case interrupted:
+		exitCode = exitInterrupted
+	case successfulFolders == 0:
+		exitCode = exitAllFoldersFailed
+	case successfulFolders < len(results):
+		exitCode = exitSomeFoldersFailed
+	}
+	// Folders with their own config pattern make a single pattern label misleading
+	quotedPattern, headerPattern := analyzer.DescribeMatch(opts.Patterns, opts.Regex, true), analyzer.DescribeMatch(opts.Patterns, opts.Regex, false)
+	for _, result := range results {
+		if analyzer.DescribeMatch(result.Patterns, result.Regex, false) != headerPattern {
+			quotedPattern, headerPattern = "the per-folder patterns", "Matching"
+			break
+		}
+	}
+
+	if jsonOutput || markdownOutput || ndjsonOutput {
+		// Self-check problems go to stderr so stdout stays a single JSON or Markdown document
+		if selfCheck {
+			if problems := checkConsistency(results); len(problems) > 0 {
+				for _, problem := range problems {
+					fmt.Fprintf(os.Stderr, "Self-check: %s\n", problem)
+				}
+				if exitCode == 0 {
+					exitCode = exitSelfCheckFailed
+				}
+			}
+		}
+		if lowDates := datesBelowThreshold(aggregateDateCountMap, minPerDay, opts.Since, opts.Until); len(lowDates) > 0 {
+			printLowVolumeAlert(os.Stderr, lowDates, aggregateDateCountMap, minPerDay)
+			if exitCode == 0 {
+				exitCode = exitBelowThreshold
+			}
+		}
+		if printBaselineComparison(os.Stderr, results, folderConfigs, baselineTolerance, report.Precision) && exitCode == 0 {
+			exitCode = exitBaselineFlagged
+		}
+
+		if markdownOutput {
+			printMarkdownReport(out, results, aggregateDateCountMap, baselineDateCountMap, distinctRecipients, quotedPattern, report, onlyFolder)
+			os.Exit(exitCode)
+		}
+
+		// The records were the output; failed folders are only reported on stderr
+		if ndjsonOutput {
+			if entryWriter.err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing NDJSON records: %v\n", entryWriter.err)
+				os.Exit(1)
+			}
+			if !warningsJSON {
+				for _, result := range results {
+					if result.Error != nil {
+						fmt.Fprintf(os.Stderr, "Error: folder %s: %v\n", result.DisplayName(), result.Error)
+					}
+				}
+			}
+			os.Exit(exitCode)
+		}
+
+		aggregate := JSONAggregate{
+			TotalFolders:        len(folderPaths),
+			SuccessfulFolders:   successfulFolders,
+			TotalCount:          totalEntriesAcrossAllFolders,
+			LinesScanned:        totalLines,
+			BytesScanned:        totalBytes,
+			SkippedLines:        totalSkipped,
+			OversizedFiles:      totalOversized,
+			DistinctDays:        len(aggregateDateCountMap),
+			DistinctRecipients:  distinctRecipients,
+			DuplicatesCollapsed: totalDuplicatesCollapsed,
+			MissingDates:        missingDates(aggregateDateCountMap, opts.Since, opts.Until),
+			Anomalies:           analyzer.SortedKeys(findAnomalies(aggregateDateCountMap, report.AnomalyThreshold)),
+			Dates:               aggregateDateCountMap,
+		}
+		if len(aggregateDateCountMap) > 0 {
+			denominatorDays, _ := averageDenominator(aggregateDateCountMap, report.Denominator)
+			aggregate.AveragePerDay = roundFloat(float64(totalEntriesAcrossAllFolders)/float64(denominatorDays), report.Precision)
+			dailyCounts := countValues(aggregateDateCountMap)
+			aggregate.MedianPerDay, aggregate.P90PerDay = percentile(dailyCounts, 50), percentile(dailyCounts, 90)
+		}
+		if report.Cumulative {
+			dates, runningTotals := cumulativeCounts(aggregateDateCountMap, report.FillGaps)
+			aggregate.Cumulative = make(map[string]int, len(dates))
+			for i, date := range dates {
+				aggregate.Cumulative[date] = runningTotals[i]
+			}
+		}
+
+		jsonReport := JSONReport{Folders: results, Aggregate: aggregate}
+		if baselineDateCountMap != nil {
+			comparison := compareRuns(aggregateDateCountMap, baselineDateCountMap, report.Precision)
+			jsonReport.Baseline = &comparison
+		}
+
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(jsonReport); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing JSON report: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(exitCode)
+	}
+
+	if !onlyFolderMatched {
+		fmt.Fprintf(out, "\nNote: --only-folder %s did not match any processed folder\n", onlyFolder)
+	}
+
+	if selfCheck {
+		if problems := checkConsistency(results); len(problems) > 0 {
+			fmt.Fprintln(out, "\n"+strings.Repeat("!", 80))
+			fmt.Fprintf(out, "SELF-CHECK FAILED: %d inconsistencies found (this is a bug)\n", len(problems))
+			for _, problem := range problems {
+				fmt.Fprintf(out, "  - %s\n", problem)
+			}
+			fmt.Fprintln(out, strings.Repeat("!", 80))
+			if exitCode == 0 {
+				exitCode = exitSelfCheckFailed
+			}
+		} else {
+			fmt.Fprintln(out, "\nSelf-check passed: per-file, per-date and per-hour counts are consistent")
+		}
+	}
+
+	if lowDates := datesBelowThreshold(aggregateDateCountMap, minPerDay, opts.Since, opts.Until); len(lowDates) > 0 {
+		printLowVolumeAlert(out, lowDates, aggregateDateCountMap, minPerDay)
+		if exitCode == 0 {
+			exitCode = exitBelowThreshold
+		}
+	}
+
+	if printBaselineComparison(out, results, folderConfigs, baselineTolerance, report.Precision) && exitCode == 0 {
+		exitCode = exitBaselineFlagged
+	}
+
+	// Scan times differ from run to run, so --stable leaves them out like the elapsed time
+	showTimings := report.Timings && !report.Stable && !follow
+	if showTimings {
+		printFolderTimings(out, results)
+	}
+
+	// Print aggregate summary
+	if len(aggregateDateCountMap) == 0 {
+		fmt.Fprintln(out, "\n"+strings.Repeat("=", 80))
+		fmt.Fprintf(out, "No entries with %s found in any log files.\n", quotedPattern)
+		if totalOversized > 0 {
+			fmt.Fprintf(out, "%d file(s) were skipped as over --max-file-size.\n", totalOversized)
+		}
+		os.Exit(exitCode)
+	}
+
+	distinctDays := len(aggregateDateCountMap)
+	denominatorDays, denominatorLabel := averageDenominator(aggregateDateCountMap, report.Denominator)
+	average := float64(totalEntriesAcrossAllFolders) / float64(denominatorDays)
+
+	// A rollup lists weeks or months instead of dates, and averages over those periods
+	periodCountMap, periodName, periodUnit := aggregateDateCountMap, "Date", "day"
+	if report.Rollup != "" {
+		periodCountMap, periodUnit = rollupDates(aggregateDateCountMap, report.Rollup), report.Rollup
+		periodName = strings.ToUpper(periodUnit[:1]) + periodUnit[1:]
+		denominatorDays, denominatorLabel = len(periodCountMap), "active "+periodUnit+"s"
+		average = float64(totalEntriesAcrossAllFolders) / float64(denominatorDays)
+	}
+
+	fmt.Fprintln(out, "\n"+strings.Repeat("=", 80))
+	fmt.Fprintln(out, "AGGREGATE RESULTS (ALL FOLDERS)")
+	fmt.Fprintln(out, strings.Repeat("=", 80))
+
+	anomalies := findAnomalies(periodCountMap, report.AnomalyThreshold)
+	fmt.Fprintf(out, "\n%s Entries by %s:\n", headerPattern, periodName)
+	if report.Cumulative {
+		printCumulative(out, periodCountMap, report.FillGaps)
+	} else if report.TopDates > 0 {
+		// Busiest first; keysByCountDesc breaks ties by ascending date
+		dates := analyzer.KeysByCountDesc(periodCountMap)
+		for _, date := range dates[:min(report.TopDates, len(dates))] {
+			fmt.Fprintf(out, "  %s: %d entries%s\n", date, periodCountMap[date], anomalyTag(anomalies, date))
+		}
+		if len(dates) > report.TopDates {
+			fmt.Fprintf(out, "  (%d more not shown)\n", len(dates)-report.TopDates)
+		}
+	} else {
+		for _, date := range analyzer.SortedKeys(periodCountMap) {
+			fmt.Fprintf(out, "  %s: %d entries%s\n", date, periodCountMap[date], anomalyTag(anomalies, date))
+		}
+	}
+
+	// With a full --since/--until range, days without any entries are worth calling out
+	if gaps := missingDates(aggregateDateCountMap, opts.Since, opts.Until); len(gaps) > 0 {
+		fmt.Fprintf(out, "\nMissing Dates (%d of %d days in range have no entries):\n", len(gaps), int(opts.Until.Sub(opts.Since).Hours()/24)+1)
+		for _, date := range gaps {
+			fmt.Fprintf(out, "  %s: MISSING\n", date)
+		}
+	}
+
+	if baselineDateCountMap != nil {
+		printRunComparison(out, aggregateDateCountMap, baselineDateCountMap, report.Precision)
+	}
+
+	if len(opts.Patterns) > 1 && opts.Regex == nil {
+		printPatternBreakdown(out, opts.Patterns, aggregatePatternDateCounts, report.Rollup)
+	}
+
+	if opts.ApproxDistinct {
+		printApproxDistinct(out, aggregateRecipientSketch, report.Precision)
+	}
+
+	if opts.ResultRegex != nil {
+		printResultBreakdown(out, aggregateResultCounts, report.Precision)
+	}
+
+	if report.Daypart {
+		printDayparts(out, results)
+	}
+
+	if report.ByWeekday {
+		printWeekdays(out, aggregateDateCountMap, report.Precision)
+	}
+
+	if opts.Schedule != nil {
+		fmt.Fprintf(out, "\nSchedule %q (±%s):\n", scheduleSpec, opts.ScheduleWindow)
+		timestamped := totalScheduleAligned + totalScheduleOff
+		if timestamped == 0 {
+			fmt.Fprintln(out, "  No entries with a parseable timestamp")
+		} else {
+			alignedShare := float64(totalScheduleAligned) / float64(timestamped) * 100
+			fmt.Fprintf(out, "  On schedule: %d entries (%s%%)\n", totalScheduleAligned, formatFloat(alignedShare, report.Precision))
+			fmt.Fprintf(out, "  Off schedule: %d entries (%s%%)\n", totalScheduleOff, formatFloat(100-alignedShare, report.Precision))
+		}
+	}
+
+	if opts.ByMinuteOfHour {
+		printMinuteOfHour(out, aggregateMinuteCounts, report.Precision)
+	}
+
+	if opts.ClientRegex != nil {
+		fmt.Fprintln(out, "\nEntries by Client:")
+		for _, client := range analyzer.KeysByCountDesc(aggregateClientCountMap) {
+			fmt.Fprintf(out, "  %s: %d entries\n", client, aggregateClientCountMap[client])
+		}
+	}
+
+	if len(aggregateDomainCountMap) > 0 {
+		domains := analyzer.KeysByCountDesc(aggregateDomainCountMap)
+		fmt.Fprintf(out, "\nTop Recipient Domains (%d of %d):\n", min(topDomains, len(domains)), len(domains))
+		for _, domain := range domains[:min(topDomains, len(domains))] {
+			fmt.Fprintf(out, "  %s: %d entries\n", domain, aggregateDomainCountMap[domain])
+		}
+	}
+
+	fmt.Fprintln(out, "\nSummary:")
+	fmt.Fprintf(out, "  Total folders processed: %d\n", len(folderPaths))
+	fmt.Fprintf(out, "  Successful folders: %d\n", successfulFolders)
+	fmt.Fprintf(out, "  Total entries with %s: %s\n", quotedPattern, report.colorize(colorBold, strconv.Itoa(totalEntriesAcrossAllFolders)))
+	fmt.Fprintf(out, "  Skipped lines (no valid date): %d\n", totalSkipped)
+	if opts.MaxFileSize > 0 {
+		fmt.Fprintf(out, "  Skipped files (over --max-file-size): %d\n", totalOversized)
+	}
+	fmt.Fprintf(out, "  Total distinct days: %d\n", distinctDays)
+	fmt.Fprintf(out, "  Distinct recipients: %s\n", recipientsLabel)
+	fmt.Fprintf(out, "  Average entries per %s: %s (over %d %s)\n", periodUnit, formatFloat(average, report.Precision), denominatorDays, denominatorLabel)
+	periodCounts := countValues(periodCountMap)
+	fmt.Fprintf(out, "  Median entries per %s: %d (p90 %d)\n", periodUnit, percentile(periodCounts, 50), percentile(periodCounts, 90))
+	if opts.IDRegex != nil {
+		fmt.Fprintf(out, "  Duplicate event IDs collapsed: %d\n", totalDuplicatesCollapsed)
+	}
+	fmt.Fprintf(out, "  Input scanned: %d lines, %s\n", totalLines, formatBytes(totalBytes))
+	if !report.Stable {
+		bytesPerSecond := float64(totalBytes) / max(elapsed.Seconds(), 0.001)
+		fmt.Fprintf(out, "  Elapsed: %s (%s/s)\n", elapsed.Round(time.Millisecond), formatBytes(int64(bytesPerSecond)))
+	}
+	if showTimings && len(results) > 0 {
+		var folderTime time.Duration
+		for _, result := range results {
+			folderTime += result.Duration
+		}
+		average := folderTime / time.Duration(len(results))
+		fmt.Fprintf(out, "  Folder time: %s total, %s average per folder\n", folderTime.Round(time.Millisecond), average.Round(time.Millisecond))
+	}
+
+	os.Exit(exitCode)
+}
+
+// printFolderTimings lists how long each folder took, slowest first (--timings). Folders are
+// scanned concurrently, so their times overlap and can add up to more than the elapsed time.
+func printFolderTimings(out io.Writer, results []analyzer.FolderResult) {
+	sorted := slices.Clone(results)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Duration > sorted[j].Duration
+	})
+
+	fmt.Fprintln(out, "\n"+strings.Repeat("=", 80))
+	fmt.Fprintln(out, "FOLDER TIMINGS (slowest first)")
+	fmt.Fprintln(out, strings.Repeat("=", 80))
+	for _, result := range sorted {
+		status := ""
+		if result.Error != nil {
+			status = " [ERROR]"
+		}
+		fmt.Fprintf(out, "  %10s  %10s  %s%s\n", result.Duration.Round(time.Millisecond), formatBytes(result.BytesScanned), result.DisplayName(), status)
+	}
+}
+
+// checkConsistency verifies the invariants between a folder's counters and returns a description
+// of each violation: per-file counts, per-date counts and (where retained) per-hour counts must
+// all add up to the same totals
+func checkConsistency(results []analyzer.FolderResult) []string {
+	var problems []string
+	for _, result := range results {
+		if result.Error != nil {
+			continue
+		}
+		name := result.DisplayName()
+
+		fileTotal := 0
+		for _, count := range result.FileCountMap {
+			fileTotal += count
+		}
+		if fileTotal != result.TotalCount {
+			problems = append(problems, fmt.Sprintf("%s: per-file counts sum to %d but the total is %d", name, fileTotal, result.TotalCount))
+		}
+
+		dateTotal := 0
+		for _, date := range analyzer.SortedKeys(result.DateCountMap) {
+			count := result.DateCountMap[date]
+			dateTotal += count
+
+			// Dates without hourly detail were pruned by --hourly-top-k, which is expected
+			hourlyData := result.DateHourlyData[date]
+			if hourlyData == nil {
+				continue
+			}
+			hourTotal := result.DateUnknownHour[date]
+			for _, hourCount := range hourlyData {
+				hourTotal += hourCount
+			}
+			if hourTotal != count {
+				problems = append(problems, fmt.Sprintf("%s: hourly counts for %s sum to %d but the daily count is %d", name, date, hourTotal, count))
+			}
+		}
+		if dateTotal != result.TotalCount {
+			problems = append(problems, fmt.Sprintf("%s: daily counts sum to %d but the total is %d", name, dateTotal, result.TotalCount))
+		}
+
+		for date := range result.DateHourlyData {
+			if _, ok := result.DateCountMap[date]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: hourly data exists for %s, which has no daily count", name, date))
+			}
+		}
+	}
+	return problems
+}
+
+// compareRuns lines up the per-date counts of the current folders and the --baseline set.
+// Dates only one side has are marked added or removed; percent changes are rounded to precision.
+func compareRuns(current, baseline map[string]int, precision int) JSONRunComparison {
+	dates := make(map[string]int, len(current)+len(baseline))
+	analyzer.MergeCounts(dates, current)
+	analyzer.MergeCounts(dates, baseline)
+
+	comparison := JSONRunComparison{Dates: []JSONDateComparison{}}
+	for _, date := range analyzer.SortedKeys(dates) {
+		now, nowOK := current[date]
+		before, beforeOK := baseline[date]
+		row := newDateComparison(date, now, before, precision)
+		switch {
+		case !beforeOK:
+			row.Status = "added"
+		case !nowOK:
+			row.Status = "removed"
+		}
+		comparison.Dates = append(comparison.Dates, row)
+		comparison.Total.Current += now
+		comparison.Total.Baseline += before
+	}
+	comparison.Total = newDateComparison("", comparison.Total.Current, comparison.Total.Baseline, precision)
+	return comparison
+}
+
+// newDateComparison fills in the delta and, when there is a baseline count, the percent change
+func newDateComparison(date string, current, baseline, precision int) JSONDateComparison {
+	row := JSONDateComparison{Date: date, Current: current, Baseline: baseline, Delta: current - baseline}
+	if baseline > 0 {
+		change := roundFloat(float64(current-baseline)/float64(baseline)*100, precision)
+		row.PercentChange = &change
+	}
+	return row
+}
+
+// formatPercentChange formats a percent change with its sign, e.g. "+6.44%"
+func formatPercentChange(change float64, precision int) string {
+	sign := ""
+	if change >= 0 {
+		sign = "+"
+	}
+	return sign + formatFloat(change, precision) + "%"
+}
+
+// printRunComparison prints the per-date change from the --baseline set to the current folders.
+// Dates only one side has are shown as added or removed.
+func printRunComparison(out io.Writer, current, baseline map[string]int, precision int) {
+	fmt.Fprintln(out, "\nComparison with Baseline (current vs baseline):")
+
+	comparison := compareRuns(current, baseline, precision)
+	for _, row := range comparison.Dates {
+		switch row.Status {
+		case "added":
+			fmt.Fprintf(out, "  %s: %d vs -, added\n", row.Date, row.Current)
+		case "removed":
+			fmt.Fprintf(out, "  %s: - vs %d, removed\n", row.Date, row.Baseline)
+		default:
+			fmt.Fprintf(out, "  %s: %d vs %d (%+d)\n", row.Date, row.Current, row.Baseline, row.Delta)
+		}
+	}
+
+	total := comparison.Total
+	fmt.Fprintf(out, "  Total: %d vs %d (%+d", total.Current, total.Baseline, total.Delta)
+	if total.PercentChange != nil {
+		fmt.Fprintf(out, ", %s", formatPercentChange(*total.PercentChange, precision))
+	}
+	fmt.Fprintln(out, ")")
+}
+
+// printMarkdownRunComparison writes the --baseline comparison as a Markdown table
+func printMarkdownRunComparison(out io.Writer, current, baseline map[string]int, precision int) {
+	fmt.Fprint(out, "\n## Comparison with Baseline\n\n")
+	fmt.Fprintln(out, "| Date | Current | Baseline | Change | Change % |")
+	fmt.Fprintln(out, "|---|---:|---:|---:|---:|")
+
+	comparison := compareRuns(current, baseline, precision)
+	for _, row := range append(comparison.Dates, comparison.Total) {
+		date, now, before, change, percent := row.Date, strconv.Itoa(row.Current), strconv.Itoa(row.Baseline), fmt.Sprintf("%+d", row.Delta), "-"
+		if date == "" {
+			date = "**Total**"
+		}
+		switch row.Status {
+		case "added":
+			before, change = "-", "added"
+		case "removed":
+			now, change = "-", "removed"
+		}
+		if row.PercentChange != nil {
+			percent = formatPercentChange(*row.PercentChange, precision)
+		}
+		fmt.Fprintf(out, "| %s | %s | %s | %s | %s |\n", date, now, before, change, percent)
+	}
+}
+
+// printBaselineComparison prints actual vs expected daily volume for folders that have a
+// baseline in the config, and reports whether any of them fell outside the tolerance
+func printBaselineComparison(out io.Writer, results []analyzer.FolderResult, folderConfigs map[string]FolderConfig, tolerance float64, precision int) bool {
+	flagged := false
+	printedHeader := false
+
+	for _, result := range results {
+		expected := folderConfigs[result.FolderPath].Expected
+		if expected <= 0 {
+			continue
+		}
+
+		if !printedHeader {
+			fmt.Fprintln(out, "\n"+strings.Repeat("=", 80))
+			fmt.Fprintf(out, "BASELINE COMPARISON (tolerance ±%g%%)\n", tolerance)
+			fmt.Fprintln(out, strings.Repeat("=", 80))
+			printedHeader = true
+		}
+
+		// A failed folder counts as zero volume, which is exactly the outage case to flag
+		actual := 0.0
+		if result.Error == nil && len(result.DateCountMap) > 0 {
+			actual = float64(result.TotalCount) / float64(len(result.DateCountMap))
+		}
+		percent := actual / float64(expected) * 100
+
+		status := "OK"
+		if percent < 100-tolerance {
+			status = "UNDER BASELINE"
+			flagged = true
+		} else if percent > 100+tolerance {
+			status = "OVER BASELINE"
+			flagged = true
+		}
+
+		fmt.Fprintf(out, "\n[%s] Folder: %s\n", status, result.DisplayName())
+		fmt.Fprintf(out, "  Expected per day: %d, actual per day: %s (%s%% of baseline)\n",
+			expected, formatFloat(actual, precision), formatFloat(percent, precision))
+	}
+
+	return flagged
+}
+
+// datesBelowThreshold returns the dates whose aggregate count is under minPerDay, in order. Only dates
+// with entries are checked, unless --since or --until is given: then every day from the since date
+// (or the first date seen) to the until date (or the last date seen) is expected, and missing days count as zero.
+func datesBelowThreshold(dateCountMap map[string]int, minPerDay int, since, until time.Time) []string {
+	if minPerDay <= 0 {
+		return nil
+	}
+
+	dates := analyzer.SortedKeys(dateCountMap)
+	if !since.IsZero() || !until.IsZero() {
+		first, last := since, until
+		if first.IsZero() && len(dates) > 0 {
+			first, _ = time.Parse("2006-01-02", dates[0])
+		}
+		if last.IsZero() && len(dates) > 0 {
+			last, _ = time.Parse("2006-01-02", dates[len(dates)-1])
+		}
+		dates = nil
+		if !first.IsZero() && !last.IsZero() {
+			for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+				dates = append(dates, day.Format("2006-01-02"))
+			}
+		}
+	}
+
+	var low []string
+	for _, date := range dates {
+		if dateCountMap[date] < minPerDay {
+			low = append(low, date)
+		}
+	}
+	return low
+}
+
+// missingDates returns, in order, the days from since to until (inclusive) without entries.
+// Both bounds must be set; otherwise the expected range is unknown and nil is returned.
+func missingDates(dateCountMap map[string]int, since, until time.Time) []string {
+	if since.IsZero() || until.IsZero() {
+		return nil
+	}
+
+	var missing []string
+	for day := since; !day.After(until); day = day.Add(24 * time.Hour) {
+		date := day.Format("2006-01-02")
+		if dateCountMap[date] == 0 {
+			missing = append(missing, date)
+		}
+	}
+	return missing
+}
+
+// ANSI escape sequences used when report.Color is set
+const (
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorBold  = "\033[1m"
+	colorReset = "\033[0m"
+)
+
+// colorize wraps text in an ANSI color sequence, or returns it unchanged when color is off
+func (r ReportOptions) colorize(color, text string) string {
+	if !r.Color {
+		return text
+	}
+	return color + text + colorReset
+}
+
+// isTerminal reports whether f is a character device such as a console, rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// printMarkdownReport writes the per-folder and aggregate counts as Markdown tables and the
+// summary as a list, for pasting into a wiki. The text-only breakdowns are left out.
+func printMarkdownReport(out io.Writer, results []analyzer.FolderResult, dateCountMap, baselineDateCountMap map[string]int, distinctRecipients int, quotedPattern string, report ReportOptions, onlyFolder string) {
+	fmt.Fprintln(out, "# Log Analysis Report")
+
+	totalEntries, successfulFolders := 0, 0
+	for _, result := range results {
+		if result.Error == nil {
+			totalEntries += result.TotalCount
+			successfulFolders++
+		}
+	}
+
+	printedHeader := false
+	for _, result := range results {
+		if onlyFolder != "" && !result.Matches(onlyFolder) || report.Quiet && result.Error == nil {
+			continue
+		}
+		if !printedHeader {
+			fmt.Fprintln(out, "\n## Results by Folder")
+			printedHeader = true
+		}
+		fmt.Fprintf(out, "\n### %s\n\n", markdownEscape(result.DisplayName()))
+		if result.Error != nil {
+			fmt.Fprintf(out, "**Error:** %s\n", markdownEscape(result.Error.Error()))
+			continue
+		}
+		printMarkdownCounts(out, "Date", result.DateCountMap)
+		fmt.Fprintf(out, "\nTotal: **%d** entries\n", result.TotalCount)
+	}
+
+	if len(dateCountMap) == 0 {
+		fmt.Fprintf(out, "\nNo entries with %s found in any log files.\n", markdownEscape(quotedPattern))
+		return
+	}
+
+	// The aggregate table follows --rollup, like the text report
+	periodCountMap, periodName, periodUnit := dateCountMap, "Date", "day"
+	denominatorDays, denominatorLabel := averageDenominator(dateCountMap, report.Denominator)
+	if report.Rollup != "" {
+		periodCountMap, periodUnit = rollupDates(dateCountMap, report.Rollup), report.Rollup
+		periodName = strings.ToUpper(periodUnit[:1]) + periodUnit[1:]
+		denominatorDays, denominatorLabel = len(periodCountMap), "active "+periodUnit+"s"
+	}
+	average := float64(totalEntries) / float64(denominatorDays)
+	periodCounts := countValues(periodCountMap)
+
+	fmt.Fprint(out, "\n## Aggregate Results (All Folders)\n\n")
+	printMarkdownCounts(out, periodName, periodCountMap)
+
+	fmt.Fprint(out, "\n## Summary\n\n")
+	fmt.Fprintf(out, "- Total folders processed: %d\n", len(results))
+	fmt.Fprintf(out, "- Successful folders: %d\n", successfulFolders)
+	fmt.Fprintf(out, "- Total entries with %s: %d\n", markdownEscape(quotedPattern), totalEntries)
+	fmt.Fprintf(out, "- Total distinct days: %d\n", len(dateCountMap))
+	fmt.Fprintf(out, "- Distinct recipients: %d\n", distinctRecipients)
+	fmt.Fprintf(out, "- Average entries per %s: %s (over %d %s)\n", periodUnit, formatFloat(average, report.Precision), denominatorDays, denominatorLabel)
+	fmt.Fprintf(out, "- Median entries per %s: %d (p90 %d)\n", periodUnit, percentile(periodCounts, 50), percentile(periodCounts, 90))
+
+	if baselineDateCountMap != nil {
+		printMarkdownRunComparison(out, dateCountMap, baselineDateCountMap, report.Precision)
+	}
+}
+
+// printMarkdownCounts writes a two-column Markdown table of counts, sorted by key
+func printMarkdownCounts(out io.Writer, keyName string, counts map[string]int) {
+	fmt.Fprintf(out, "| %s | Count |\n", keyName)
+	fmt.Fprintln(out, "|---|---:|")
+	for _, key := range analyzer.SortedKeys(counts) {
+		fmt.Fprintf(out, "| %s | %d |\n", markdownEscape(key), counts[key])
+	}
+}
+
+// markdownEscape keeps folder names and error messages from breaking tables or emphasis
+var markdownEscape = strings.NewReplacer(`\`, `\\`, "|", `\|`, "*", `\*`, "_", `\_`, "`", "\\`", "<", "&lt;").Replace
+
+// printLowVolumeAlert lists the dates found by datesBelowThreshold
+func printLowVolumeAlert(out io.Writer, lowDates []string, dateCountMap map[string]int, minPerDay int) {
+	fmt.Fprintln(out, "\n"+strings.Repeat("!", 80))
+	fmt.Fprintf(out, "LOW VOLUME ALERT: %d date(s) below %d entries\n", len(lowDates), minPerDay)
+	for _, date := range lowDates {
+		fmt.Fprintf(out, "  - %s: %d entries\n", date, dateCountMap[date])
+	}
+	fmt.Fprintln(out, strings.Repeat("!", 80))
+}
+
+// printFolderResult prints the detailed section for a single folder
+func printFolderResult(out io.Writer, result analyzer.FolderResult, report ReportOptions) {
+	if result.Error != nil {
+		fmt.Fprintf(out, "\n%s Folder: %s\n", report.colorize(colorRed, "[ERROR]"), result.DisplayName())
+		if report.Verbose && result.Label != "" {
+			fmt.Fprintf(out, "  Path: %s\n", result.FolderPath)
+		}
+		fmt.Fprintf(out, "  %s\n", report.colorize(colorRed, fmt.Sprintf("Error: %v", result.Error)))
+		return
+	}
+
+	fmt.Fprintf(out, "\n%s Folder: %s\n", report.colorize(colorGreen, "[SUCCESS]"), result.DisplayName())
+	if report.Verbose && result.Label != "" {
+		fmt.Fprintf(out, "  Path: %s\n", result.FolderPath)
+	}
+
+	// Show per-file counts if verbose mode is enabled
+	if report.Verbose && len(result.FileCountMap)+len(result.OversizedFiles) > 0 {
+		fmt.Fprintln(out, "  Files:")
+		fileNames := analyzer.SortedKeys(result.FileCountMap)
+		if report.FilesByCount {
+			fileNames = analyzer.KeysByCountDesc(result.FileCountMap)
+		}
+		for _, fileName := range fileNames {
+			note := ""
+			if result.CappedFiles[fileName] {
+				note = " (capped)"
+			}
+			if result.AbortedFiles[fileName] {
+				note = " (aborted: too many parse errors)"
+			}
+			count := result.FileCountMap[fileName]
+			if count == 0 {
+				fmt.Fprintf(out, "    - %s: no entries%s\n", fileName, note)
+				continue
+			}
+			span := ""
+			if timeRange, ok := result.FileTimeRanges[fileName]; ok {
+				span = fmt.Sprintf(", %s → %s", timeRange.First.Format("2006-01-02 15:04"), timeRange.Last.Format("2006-01-02 15:04"))
+			}
+			fmt.Fprintf(out, "    - %s: %d entries%s%s\n", fileName, count, span, note)
+		}
+		for _, fileName := range analyzer.SortedKeys(result.OversizedFiles) {
+			fmt.Fprintf(out, "    - %s: skipped, %s is over --max-file-size\n", fileName, formatBytes(result.OversizedFiles[fileName]))
+		}
+	}
+
+	// Show per-day statistics with average emails per hour if verbose mode is enabled
+	if report.Verbose && len(result.DateCountMap) > 0 {
+		fmt.Fprintln(out, "  Per-Day Statistics:")
+		for _, date := range analyzer.SortedKeys(result.DateCountMap) {
+			count := result.DateCountMap[date]
+			if result.DateHourlyData[date] == nil {
+				fmt.Fprintf(out, "    - %s: %d entries (hourly detail not retained)\n", date, count)
+				continue
+			}
+
+			// Calculate average emails per hour (or minute) for this date
+			if result.MinuteBuckets {
+				avgPerMinute := analyzer.AveragePerBucket(result.DateHourlyData[date], count, result.IsExcludedBucket)
+				peakMinute, peakCount := analyzer.PeakBucket(result.DateHourlyData[date], 24*60)
+				fmt.Fprintf(out, "    - %s: %d entries (avg %s emails/minute, peak %02d:%02d with %d entries)\n",
+					date, count, formatFloat(avgPerMinute, report.Precision), peakMinute/60, peakMinute%60, peakCount)
+				continue
+			}
+			avgPerHour := analyzer.AveragePerBucket(result.DateHourlyData[date], count, result.IsExcludedBucket)
+			peakHour, peakCount := analyzer.PeakBucket(result.DateHourlyData[date], 24)
+			fmt.Fprintf(out, "    - %s: %d entries (avg %s emails/hour, peak %02d:00 with %d entries)\n",
+				date, count, formatFloat(avgPerHour, report.Precision), peakHour, peakCount)
+		}
+	}
+
+	if report.Histogram && len(result.DateHourlyData) > 0 {
+		fmt.Fprintln(out, "  Hourly Histogram (00-23, scaled to each day's busiest hour):")
+		for _, date := range analyzer.SortedKeys(result.DateHourlyData) {
+			fmt.Fprintf(out, "    %s |%s|\n", date, renderHistogram(result.HourlyCounts(date)))
+		}
+	}
+
+	fmt.Fprintf(out, "  Total %s entries: %s\n", analyzer.DescribeMatch(result.Patterns, result.Regex, true), report.colorize(colorBold, strconv.Itoa(result.TotalCount)))
+	if totals := result.PatternTotals(); totals != nil {
+		fmt.Fprintln(out, "  By pattern:")
+		for _, pattern := range result.Patterns {
+			fmt.Fprintf(out, "    - '%s': %d entries\n", pattern, totals[pattern])
+		}
+	}
+	if result.DuplicateCount > 0 {
+		fmt.Fprintf(out, "  Duplicate event IDs collapsed: %d\n", result.DuplicateCount)
+	}
+	if result.SkippedCount > 0 {
+		fmt.Fprintf(out, "  Skipped lines (no valid date): %d\n", result.SkippedCount)
+		if report.Verbose {
+			for _, line := range result.SkippedSamples {
+				fmt.Fprintf(out, "    > %s\n", line)
+			}
+		}
+	}
+	if report.Verbose && len(result.ClientCountMap) > 0 {
+		fmt.Fprintln(out, "  By client:")
+		for _, client := range analyzer.KeysByCountDesc(result.ClientCountMap) {
+			fmt.Fprintf(out, "    - %s: %d entries\n", client, result.ClientCountMap[client])
+		}
+	}
+	if report.Verbose && len(result.DomainCountMap) > 0 {
+		fmt.Fprintln(out, "  By domain:")
+		for _, domain := range analyzer.KeysByCountDesc(result.DomainCountMap) {
+			fmt.Fprintf(out, "    - %s: %d entries\n", domain, result.DomainCountMap[domain])
+		}
+	}
+	if len(result.OverlappingFiles) > 0 {
+		fmt.Fprintf(out, "  Overlapping file pairs: %d\n", len(result.OverlappingFiles))
+	}
+	if len(result.DateResultCounts) > 0 {
+		totals := make(map[string]int)
+		for _, buckets := range result.DateResultCounts {
+			for bucket, count := range buckets {
+				totals[bucket] += count
+			}
+		}
+		fmt.Fprintf(out, "  Results: %s\n", formatResultCounts(totals, report.Precision))
+	}
+}
+
+// rollupDates sums per-date counts into ISO weeks ("2024-W09") or months ("2024-03"),
+// keyed so that a plain string sort is chronological
+func rollupDates(dateCountMap map[string]int, period string) map[string]int {
+	periodCountMap := make(map[string]int)
+	for dateStr, count := range dateCountMap {
+		date, err := time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			continue
+		}
+		key := date.Format("2006-01")
+		if period == "week" {
+			year, week := date.ISOWeek()
+			key = fmt.Sprintf("%04d-W%02d", year, week)
+		}
+		periodCountMap[key] += count
+	}
+	return periodCountMap
+}
+
+// printCumulative prints each date's count with the running total up to and including that date
+func printCumulative(out io.Writer, dateCountMap map[string]int, fillGaps bool) {
+	dates, runningTotals := cumulativeCounts(dateCountMap, fillGaps)
+	for i, date := range dates {
+		fmt.Fprintf(out, "  %s: %d entries (cumulative %d)\n", date, dateCountMap[date], runningTotals[i])
+	}
+}
+
+// cumulativeCounts returns the dates in order, with every day in between when fillGaps is set,
+// and the running total up to and including each of them
+func cumulativeCounts(dateCountMap map[string]int, fillGaps bool) (dates []string, runningTotals []int) {
+	dates = analyzer.SortedKeys(dateCountMap)
+	if fillGaps {
+		dates = fillDateGaps(dates)
+	}
+
+	runningTotals = make([]int, len(dates))
+	runningTotal := 0
+	for i, date := range dates {
+		runningTotal += dateCountMap[date]
+		runningTotals[i] = runningTotal
+	}
+	return dates, runningTotals
+}
+
+// fillDateGaps returns every date from the first to the last of the sorted input dates
+func fillDateGaps(dates []string) []string {
+	if len(dates) == 0 {
+		return dates
+	}
+
+	first, errFirst := time.Parse("2006-01-02", dates[0])
+	last, errLast := time.Parse("2006-01-02", dates[len(dates)-1])
+	if errFirst != nil || errLast != nil {
+		return dates
+	}
+
+	var filled []string
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		filled = append(filled, day.Format("2006-01-02"))
+	}
+	return filled
+}
+
+// findAnomalies returns the dates whose count is more than threshold population standard deviations
+// from the mean of all counts, with their z-scores. A threshold of 0 or identical counts find none.
+func findAnomalies(dateCountMap map[string]int, threshold float64) map[string]float64 {
+	anomalies := make(map[string]float64)
+	if threshold <= 0 || len(dateCountMap) == 0 {
+		return anomalies
+	}
+
+	mean := 0.0
+	for _, count := range dateCountMap {
+		mean += float64(count)
+	}
+	mean /= float64(len(dateCountMap))
+
+	variance := 0.0
+	for _, count := range dateCountMap {
+		variance += (float64(count) - mean) * (float64(count) - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(dateCountMap)))
+	if stddev == 0 {
+		return anomalies
+	}
+
+	for date, count := range dateCountMap {
+		if z := (float64(count) - mean) / stddev; math.Abs(z) > threshold {
+			anomalies[date] = z
+		}
+	}
+	return anomalies
+}
+
+// anomalyTag is the suffix for a date in the aggregate listing, e.g. "  ANOMALY (+2.4 sd)"
+func anomalyTag(anomalies map[string]float64, date string) string {
+	z, ok := anomalies[date]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("  ANOMALY (%+.1f sd)", z)
+}
+
+// countValues returns the counts of a count map in ascending order
+func countValues(m map[string]int) []int {
+	values := make([]int, 0, len(m))
+	for _, count := range m {
+		values = append(values, count)
+	}
+	sort.Ints(values)
+	return values
+}
+
+// percentile returns the p-th percentile (0-100) of ascending values by the nearest-rank method,
+// so the result is always one of the values. It returns 0 for no values.
+func percentile(values []int, p float64) int {
+	if len(values) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(values))))
+	return values[min(max(rank, 1), len(values))-1]
+}
+
+// averageDenominator returns the number of days the daily average divides by and how to label it:
+// days with entries (active-days), every day from the first to the last date (calendar-days),
+// or a fixed number of days given on the command line
+func averageDenominator(dateCountMap map[string]int, denominator string) (int, string) {
+	switch denominator {
+	case "active-days":
+		return len(dateCountMap), "active days"
+	case "calendar-days":
+		dates := analyzer.SortedKeys(dateCountMap)
+		first, errFirst := time.Parse("2006-01-02", dates[0])
+		last, errLast := time.Parse("2006-01-02", dates[len(dates)-1])
+		if errFirst != nil || errLast != nil {
+			return len(dateCountMap), "active days"
+		}
+		return int(last.Sub(first).Hours()/24) + 1, "calendar days"
+	default:
+		var days int
+		fmt.Sscanf(denominator, "%d", &days)
+		return days, "custom days"
+	}
+}
+
+// formatFloat renders a float with the number of decimals chosen by --precision
+func formatFloat(value float64, precision int) string {
+	return strconv.FormatFloat(value, 'f', precision, 64)
+}
+
+// roundFloat rounds value to precision decimals, as formatFloat prints it, for numeric output
+func roundFloat(value float64, precision int) float64 {
+	rounded, _ := strconv.ParseFloat(formatFloat(value, precision), 64)
+	return rounded
+}
+
+// formatBytes renders a byte count with a binary unit, e.g. "1.5 MB"
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	value, suffix := float64(bytes), 0
+	for value >= unit && suffix < 4 {
+		value /= unit
+		suffix++
+	}
+	return fmt.Sprintf("%.1f %s", value, []string{"B", "KB", "MB", "GB", "TB"}[suffix])
+}
+
+// parseByteSize parses a size such as "500MB", "2G" or "1048576" (bytes). Units are powers of
+// 1024, like formatBytes, and case-insensitive.
+func parseByteSize(text string) (int64, error) {
+	text = strings.ToUpper(strings.TrimSpace(text))
+	multiplier := int64(1)
+	for i, unit := range []string{"K", "M", "G", "T"} {
+		if trimmed, ok := strings.CutSuffix(strings.TrimSuffix(text, "B"), unit); ok {
+			text, multiplier = trimmed, int64(1)<<(10*(i+1))
+			break
+		}
+	}
+	if multiplier == 1 {
+		text = strings.TrimSuffix(text, "B")
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid size %q", text)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+func printUsage() {
+	fmt.Println("Usage:")
+	fmt.Println("  analyze_logs [options] <folder_path1> [folder_path2] ...")
+	fmt.Println("  analyze_logs [options] --config <config_file>")
+	fmt.Println("  analyze_logs gen [gen_options] <output_folder>")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --verbose                    Show detailed per-file statistics")
+	fmt.Println("  --quiet                      Print only the aggregate results and folder errors")
+	fmt.Println("  --no-color                   Don't color the report, even on a terminal (also NO_COLOR=1)")
+	fmt.Println("  --files-by-count             List files in verbose output busiest first")
+	fmt.Println("  --precision <n>              Decimals for averages, ratios and percentages (default 2)")
+	fmt.Println("  --exclude-hours <list>       Drop entries in these hours, e.g. \"2,3\" for a nightly batch window")
+	fmt.Println("  --granularity <unit>         Bucket each day by hour (default) or minute for verbose peaks")
+	fmt.Println("  --by-minute-of-hour          Report the distribution of entries over minutes 00-59")
+	fmt.Println("  --denominator <d>            Daily average divisor: active-days (default), calendar-days or a number")
+	fmt.Println("  --rollup <period>            List the aggregate by week or month instead of by day")
+	fmt.Println("  --histogram                  Draw a 24-hour bar chart for each date in the folder sections")
+	fmt.Println("  --min-per-day <n>            Alert (exit code 3) when a date has fewer than n entries in total")
+	fmt.Println("  --top <n>                    List only the n busiest dates in the aggregate")
+	fmt.Println("  --flag-anomalies             Tag aggregate dates far from the mean daily count with ANOMALY")
+	fmt.Println("  --stddev-threshold <n>       With --flag-anomalies, standard deviations that count as far (default 2)")
+	fmt.Println("  --cumulative                 Show a running total next to each date's count")
+	fmt.Println("  --fill-gaps                  With --cumulative, list days without entries as zero")
+	fmt.Println("  --by-weekday                 Report totals and averages per day of the week, Monday first")
+	fmt.Println("  --daypart                    Report counts by night/morning/afternoon/evening")
+	fmt.Println("  --stable                     Sort all listings and omit run-specific details (diff-friendly)")
+	fmt.Println("  --pattern <text>             Count lines containing this text (default \"2FA - Email\"); repeat for several")
+	fmt.Println("  --regex <regex>              Count lines matching this regular expression instead of --pattern")
+	fmt.Println("  --ignore-case                Match --pattern, --regex and config patterns regardless of case")
+	fmt.Println("  --ext <list>                 Comma-separated file extensions to scan (default txt)")
+	fmt.Println("  --include <glob>             Only scan files whose name matches (repeatable, e.g. \"smtp-*.txt\")")
+	fmt.Println("  --exclude <glob>             Skip files whose name matches (repeatable; wins over --include)")
+	fmt.Println("  --recursive                  Also scan log files in subfolders")
+	fmt.Println("  --follow-symlinks            With --recursive, also scan symlinked subfolders (skipped by default)")
+	fmt.Println("  --output <file>              Write the report to a file instead of stdout (warnings go to stderr)")
+	fmt.Println("  --json                       Write per-folder and aggregate counts to stdout as JSON")
+	fmt.Println("  --format <format>            Report format: text (default), markdown, json (same as --json)")
+	fmt.Println("                               or ndjson (same as --ndjson)")
+	fmt.Println("  --ndjson                     Stream each counted line to stdout as a JSON record, no summary")
+	fmt.Println("  --config <file>              Load folder paths from a JSON config file (- for stdin)")
+	fmt.Println("  --csv <file>                 Write aggregate date,count rows as CSV")
+	fmt.Println("  --html <file>                Write a self-contained HTML page with a bar chart of daily counts")
+	fmt.Println("  --csv-by-folder              With --csv, write folder,date,count rows per folder instead")
+	fmt.Println("  --parquet <file>             Write per-folder hourly counts as a Parquet file")
+	fmt.Println("  --es-bulk <file>             Write Elasticsearch bulk actions (one document per folder and date)")
+	fmt.Println("  --es-bulk-mode <mode>        folder-date (default) or line for one document per matched line")
+	fmt.Println("  --es-index <template>        Bulk index name; {date} and {month} are replaced (default mailchecker-2fa)")
+	fmt.Println("  --only-folder <path>         Print the detailed section for only this folder (path or label)")
+	fmt.Println("  --baseline <path>            Compare daily counts with this folder, glob or .json config (repeatable)")
+	fmt.Println("  --baseline-tolerance <pct>   Flag folders this far from their config \"expected\" (default 50)")
+	fmt.Println("  --id-regex <regex>           Count each event ID (first capture group) once per day")
+	fmt.Println("  --result-field <name>        Split entries into success/failure by a name=value field")
+	fmt.Println("  --result-regex <regex>       Split entries into success/failure by a regex capture group")
+	fmt.Println("  --client-regex <regex>       Group entries by client using a regex capture group")
+	fmt.Println("  --self-check                 Verify per-file, per-date and per-hour counts agree")
+	fmt.Println("  --list-files                 Print the sorted absolute paths of files to scan, then exit")
+	fmt.Println("  --dry-run                    List the files each folder would scan, without reading them, then exit")
+	fmt.Println("  --warnings-json              Write warnings and folder errors to stderr as JSON lines")
+	fmt.Println("  --warnings-file <file>       Write JSON warnings and folder errors to a file instead of stderr")
+	fmt.Println("  --max-matches-per-file <n>   Stop scanning a file after n matches")
+	fmt.Println("  --max-line-bytes <n>         Skip (with a warning) lines longer than n bytes (default 4194304)")
+	fmt.Println("  --check-overlap              Warn when files in a folder cover overlapping dates")
+	fmt.Println("  --hourly-top-k <k>           Keep hourly detail only for the k busiest dates per folder")
+	fmt.Println("  --max-error-rate <0-1>       Abandon a file when this fraction of sampled matching lines fail to parse")
+	fmt.Println("  --error-sample <n>           Matching lines sampled per file for --max-error-rate (default 100)")
+	fmt.Println("  --line-prefix <prefix>       Only consider lines starting with this prefix")
+	fmt.Println("  --schedule <cron>            Split entries into on/off schedule for a cron expression")
+	fmt.Println("  --schedule-tolerance <d>     How close to an activation counts as on schedule (default 5m)")
+	fmt.Println("  --timestamp-layout <layout>  Find timestamps with a Go layout, e.g. \"[02/01/2006 15:04:05]\"")
+	fmt.Println("  --tz <zone>                  Convert timestamps into this zone (e.g. America/New_York) before bucketing")
+	fmt.Println("  --since <YYYY-MM-DD>         Only count entries on or after this date")
+	fmt.Println("  --until <YYYY-MM-DD>         Only count entries on or before this date")
+	fmt.Println("  --workers <n>                Folders processed at once (default: number of CPUs)")
+	fmt.Println("  --progress                   Print a line to stderr as each folder finishes")
+	fmt.Println("  --timings                    List each folder's scan time, slowest first")
+	fmt.Println("  --cache <file>               Reuse results of unchanged files from earlier runs, stored in this file")
+	fmt.Println("  --follow                     Keep counting lines appended to the files until Ctrl+C, then report")
+	fmt.Println("  --file-workers <n>           Files of one folder scanned at once (default: number of CPUs)")
+	fmt.Println("  --max-file-size <size>       Skip files larger than this, e.g. 500MB (default: no limit)")
+	fmt.Println("  --folder-timeout <duration>  Fail a folder that takes longer than this, e.g. 30s (default: no limit)")
+	fmt.Println("  --retries <n>                Retry opening a file n times after a transient error (default 2)")
+	fmt.Println("  --retry-delay <duration>     Wait before the first retry, doubled for each one after (default 200ms)")
+	fmt.Println("  --sequential                 Process folders one at a time for deterministic output")
+	fmt.Println("  --strict                     Fail a folder on the first matching line without a valid date")
+	fmt.Println("  --approx-distinct            Estimate distinct recipients per day with a HyperLogLog sketch")
+	fmt.Println()
+	fmt.Println("Gen options:")
+	fmt.Println("  --files <n>                  Number of log files to write (default 5)")
+	fmt.Println("  --lines <n>                  Lines per log file (default 500)")
+	fmt.Println("  --rate <0-1>                 Fraction of lines that are '2FA - Email' entries (default 0.6)")
+	fmt.Println("  --start <YYYY-MM-DD>         First date of the generated range (default 2024-01-01)")
+	fmt.Println("  --end <YYYY-MM-DD>           Last date of the generated range (default 2024-01-31)")
+	fmt.Println("  --seed <n>                   Random seed for reproducible output (default 1)")
+	fmt.Println("  --edge-cases                 Include bad dates, CRLF line endings and a UTF-8 BOM")
+	fmt.Println()
+	fmt.Println("Examples:")
+	fmt.Println("  analyze_logs C:\\Logs\\Folder1")
+	fmt.Println("  analyze_logs C:\\Logs\\Folder1 D:\\Logs\\Folder2 --verbose")
+	fmt.Println("  analyze_logs \"D:\\Logs\\2024-03-*\"")
+	fmt.Println("  analyze_logs --config config.json --verbose")
+	fmt.Println("  generate_config | analyze_logs --config -")
+	fmt.Println("  analyze_logs \\\\\\server1\\share\\logs \\\\\\server2\\share\\logs")
+	fmt.Println("  analyze_logs gen --files 10 --rate 0.5 --seed 42 --edge-cases TestLogs")
+	fmt.Println()
+	fmt.Println("Exit codes:")
+	fmt.Println("    0  Success")
+	fmt.Println("    1  Invalid arguments or configuration")
+	fmt.Println("    2  Some folders could not be processed (the others were reported)")
+	fmt.Println("    3  A date is below --min-per-day")
+	fmt.Println("    4  No folder could be processed")
+	fmt.Println("    5  A folder is outside its expected daily baseline")
+	fmt.Println("    6  --self-check found inconsistent counts")
+	fmt.Println("  130  Interrupted with Ctrl+C (partial results were reported)")
+}
+
+func loadConfigFile(configPath string, logger *analyzer.WarningLogger) (Config, error) {
+	// "-" reads the config from stdin so it can be piped in from another tool
+	var input io.Reader = os.Stdin
+	if configPath != "-" {
+		file, err := os.Open(configPath)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to open config file: %w", err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// Unknown keys are usually typos ("folder" for "folders") that would otherwise be ignored silently
+	var config Config
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		offset := decoder.InputOffset()
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &syntaxErr) {
+			offset = syntaxErr.Offset
+		} else if errors.As(err, &typeErr) {
+			offset = typeErr.Offset
+		} else if keyOffset := unknownKeyOffset(data, err); keyOffset >= 0 {
+			offset = keyOffset
+		}
+		return Config{}, fmt.Errorf("failed to parse config file at line %d: %w", lineAtOffset(data, offset), err)
+	}
+
+	if len(config.Folders) == 0 {
+		return Config{}, fmt.Errorf("no folders specified in config file")
+	}
+	for i, folder := range config.Folders {
+		if strings.TrimSpace(folder.Path) == "" {
+			return Config{}, fmt.Errorf("folder entry %d in config file has an empty path", i+1)
+		}
+	}
+	if config.Workers < 0 {
+		return Config{}, fmt.Errorf("invalid workers value %d in config file", config.Workers)
+	}
+	for _, field := range []struct{ key, date string }{{"since", config.Since}, {"until", config.Until}} {
+		if _, err := time.Parse("2006-01-02", field.date); field.date != "" && err != nil {
+			return Config{}, fmt.Errorf("invalid %s date %q in config file, expected YYYY-MM-DD", field.key, field.date)
+		}
+	}
+
+	// Expanded first, so "~/logs" and "$HOME/logs" are checked and recognized as the same folder
+	for i := range config.Folders {
+		config.Folders[i].Path = expandPath(config.Folders[i].Path)
+	}
+	config.Folders = dedupeFolderConfigs(config.Folders, logger)
+
+	// Missing folders still fail later with a folder error; the warning points back at the config
+	for _, folder := range config.Folders {
+		if strings.ContainsAny(folder.Path, "*?[") {
+			continue
+		}
+		if _, err := os.Stat(folder.Path); err != nil {
+			logger.Warnf(folder.Path, "", "config folder %s does not exist or is not accessible: %v", folder.Path, err)
+		}
+	}
+	return config, nil
+}
+
+// unknownKeyOffset locates the key named by an "unknown field" decode error in data, since the
+// error itself carries no position. It returns -1 for other errors or when the key isn't found.
+func unknownKeyOffset(data []byte, err error) int64 {
+	_, key, found := strings.Cut(err.Error(), "unknown field ")
+	if !found {
+		return -1
+	}
+	keyPattern := regexp.MustCompile(regexp.QuoteMeta(key) + `\s*:`)
+	if location := keyPattern.FindIndex(data); location != nil {
+		return int64(location[0])
+	}
+	return -1
+}
+
+// lineAtOffset returns the 1-based line number of a byte offset in data
+func lineAtOffset(data []byte, offset int64) int {
+	offset = min(max(offset, 0), int64(len(data)))
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// dedupeFolderConfigs merges config entries that resolve to the same folder, keeping the
+// first entry's path and filling in settings it lacks from the later duplicates
+func dedupeFolderConfigs(folders []FolderConfig, logger *analyzer.WarningLogger) []FolderConfig {
+	var unique []FolderConfig
+	seen := make(map[string]int) // canonical path -> index in unique
+
+	for _, folder := range folders {
+		key := canonicalFolderPath(folder.Path)
+		index, duplicate := seen[key]
+		if !duplicate {
+			seen[key] = len(unique)
+			unique = append(unique, folder)
+			continue
+		}
+
+		logger.Warnf(folder.Path, "", "config folder %s is the same as %s; merging to avoid double-counting", folder.Path, unique[index].Path)
+		unique[index].fillFrom(folder)
+	}
+
+	return unique
+}
+
+// fillFrom copies the settings f lacks from a duplicate entry for the same folder
+func (f *FolderConfig) fillFrom(duplicate FolderConfig) {
+	if f.Expected == 0 {
+		f.Expected = duplicate.Expected
+	}
+	if f.Label == "" {
+		f.Label = duplicate.Label
+	}
+	if f.Pattern == "" {
+		f.Pattern = duplicate.Pattern
+	}
+}
+
+// dedupeFolderPaths drops folders that resolve to one already listed, keeping the order of first
+// appearance. It returns the remaining paths and, for each dropped path, the path it duplicates.
+func dedupeFolderPaths(paths []string, logger *analyzer.WarningLogger) ([]string, map[string]string) {
+	var unique []string
+	seen := make(map[string]string) // canonical path -> path kept
+	duplicates := make(map[string]string)
+
+	for _, path := range paths {
+		key := canonicalFolderPath(path)
+		if kept, duplicate := seen[key]; duplicate {
+			logger.Warnf(path, "", "skipping %s, already listed as %s", path, kept)
+			duplicates[path] = kept
+			continue
+		}
+		seen[key] = path
+		unique = append(unique, path)
+	}
+
+	return unique, duplicates
+}
+
+// resolveBaselinePaths turns the --baseline arguments into folder paths. A .json argument is read
+// as a config file, whose per-folder patterns are added to folderPatterns; anything else is a
+// folder, log file or glob, as on the command line.
+func resolveBaselinePaths(inputs []string, folderPatterns map[string]string, logger *analyzer.WarningLogger) []string {
+	var paths []string
+	for _, input := range inputs {
+		if !strings.EqualFold(filepath.Ext(input), ".json") {
+			paths = append(paths, expandFolderPattern(expandPath(input), logger)...)
+			continue
+		}
+		config, err := loadConfigFile(input, logger)
+		if err != nil {
+			fmt.Printf("Error loading baseline config file: %v\n", err)
+			os.Exit(1)
+		}
+		for _, folder := range config.Folders {
+			for _, match := range expandFolderPattern(folder.Path, logger) {
+				paths = append(paths, match)
+				if folder.Pattern != "" {
+					folderPatterns[match] = folder.Pattern
+				}
+			}
+		}
+	}
+	paths, _ = dedupeFolderPaths(paths, logger)
+	return paths
+}
+
+// envVarPattern matches $VAR and ${VAR}; windowsEnvVarPattern matches %VAR%
+var (
+	envVarPattern        = regexp.MustCompile(`\$\{(\w+)\}|\$(\w+)`)
+	windowsEnvVarPattern = regexp.MustCompile(`%(\w+)%`)
 )
 
-// Config structure for JSON config file
-type Config struct {
-	Folders []string `json:"folders"`
+// expandPath expands a leading ~ to the user's home folder and environment variables in a folder
+// path, since config files and quoted arguments don't get a shell's expansion. $VAR and ${VAR}
+// work everywhere, and %VAR% on Windows only, as cmd.exe writes them. A variable that
+// isn't set is left as written, so an admin share such as \\server\C$\Logs keeps its name.
+func expandPath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = home + path[1:]
+		}
+	}
+
+	expand := func(pattern *regexp.Regexp) {
+		path = pattern.ReplaceAllStringFunc(path, func(reference string) string {
+			match := pattern.FindStringSubmatch(reference)
+			name := match[1]
+			if name == "" && len(match) > 2 {
+				name = match[2]
+			}
+			if value, ok := os.LookupEnv(name); ok {
+				return value
+			}
+			return reference
+		})
+	}
+	expand(envVarPattern)
+	if runtime.GOOS == "windows" {
+		expand(windowsEnvVarPattern)
+	}
+	return path
+}
+
+// replayWarnings passes warnings recorded as JSON lines, before the logger for the run was set
+// up, on to that logger
+func replayWarnings(recorded *bytes.Buffer, logger *analyzer.WarningLogger) {
+	decoder := json.NewDecoder(recorded)
+	for {
+		var diagnostic analyzer.Diagnostic
+		if decoder.Decode(&diagnostic) != nil {
+			return
+		}
+		logger.Warnf(diagnostic.Folder, diagnostic.File, "%s", diagnostic.Message)
+	}
+}
+
+// canonicalFolderPath resolves a folder to an absolute, symlink-free path for comparison.
+// Windows paths are case-insensitive, so they are compared in lower case there.
+func canonicalFolderPath(path string) string {
+	canonical := filepath.Clean(path)
+	if abs, err := filepath.Abs(canonical); err == nil {
+		canonical = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(canonical); err == nil {
+		canonical = resolved
+	}
+	if runtime.GOOS == "windows" {
+		canonical = strings.ToLower(canonical)
+	}
+	return canonical
 }
 
-// FolderResult stores the results for a single folder
-type FolderResult struct {
-	FolderPath     string
-	DateCountMap   map[string]int
-	FileCountMap   map[string]int
-	DateHourlyData map[string]map[int]int // date -> hour -> count
-	TotalCount     int
-	Error          error
+// ndjsonRecord is one counted line in --ndjson output
+type ndjsonRecord struct {
+	Folder string `json:"folder"`
+	File   string `json:"file"`
+	Date   string `json:"date"`
+	Hour   *int   `json:"hour,omitempty"` // left out when the hour could not be parsed
+	Line   string `json:"line"`
 }
 
-func main() {
-	if len(os.Args) < 2 {
-		printUsage()
-		os.Exit(1)
+// ndjsonWriter streams counted lines as newline-delimited JSON (--ndjson). Folders and their
+// files are scanned concurrently, so each record is written whole under a mutex.
+type ndjsonWriter struct {
+	mu            sync.Mutex
+	out           io.Writer
+	folderConfigs map[string]FolderConfig
+	err           error // first write error; later records are dropped
+}
+
+func (w *ndjsonWriter) writeEntry(folderPath string, entry analyzer.MatchedEntry) {
+	record := ndjsonRecord{Folder: folderPath, File: entry.File, Date: entry.Date, Line: entry.Line}
+	if label := w.folderConfigs[folderPath].Label; label != "" {
+		record.Folder = label
+	}
+	if entry.Hour >= 0 {
+		record.Hour = &entry.Hour
 	}
+	data, err := json.Marshal(record)
 
-	var folderPaths []string
-	verbose := false
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return
+	}
+	if err == nil {
+		_, err = w.out.Write(append(data, '\n'))
+	}
+	w.err = err
+}
 
-	// Parse command line arguments
-	for i := 1; i < len(os.Args); i++ {
-		arg := os.Args[i]
-		if arg == "--verbose" {
-			verbose = true
-		} else if arg == "--config" {
-			if i+1 >= len(os.Args) {
-				fmt.Println("Error: --config flag requires a file path")
-				os.Exit(1)
-			}
-			configPath := os.Args[i+1]
-			paths, err := loadConfigFile(configPath)
+// writeESBulkFile writes newline-delimited Elasticsearch bulk index actions, one document per
+// (folder, date) or per counted line. The index template may use {date} and {month}.
+func writeESBulkFile(path string, results []analyzer.FolderResult, mode, indexTemplate string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create bulk file: %w", err)
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	writePair := func(date string, document any) error {
+		index := strings.NewReplacer("{date}", date, "{month}", date[:min(7, len(date))]).Replace(indexTemplate)
+		action := map[string]map[string]string{"index": {"_index": index}}
+		for _, line := range []any{action, document} {
+			data, err := json.Marshal(line)
 			if err != nil {
-				fmt.Printf("Error loading config file: %v\n", err)
-				os.Exit(1)
+				return err
 			}
-			folderPaths = append(folderPaths, paths...)
-			i++ // Skip next argument (config file path)
-		} else if !strings.HasPrefix(arg, "--") {
-			// It's a folder path
-			folderPaths = append(folderPaths, arg)
+			writer.Write(append(data, '\n'))
 		}
+		return nil
 	}
 
-	if len(folderPaths) == 0 {
-		fmt.Println("Error: No folder paths provided")
-		printUsage()
-		os.Exit(1)
+	for _, result := range results {
+		if result.Error != nil {
+			continue
+		}
+
+		if mode == "line" {
+			for _, entry := range result.Entries {
+				document := map[string]any{
+					"folder": result.DisplayName(),
+					"file":   entry.File,
+					"date":   entry.Date,
+					"line":   entry.Line,
+				}
+				if entry.Hour >= 0 {
+					document["hour"] = entry.Hour
+				}
+				if err := writePair(entry.Date, document); err != nil {
+					return fmt.Errorf("failed to encode bulk document: %w", err)
+				}
+			}
+			continue
+		}
+
+		for _, date := range analyzer.SortedKeys(result.DateCountMap) {
+			document := map[string]any{
+				"folder": result.DisplayName(),
+				"date":   date,
+				"count":  result.DateCountMap[date],
+			}
+			if err := writePair(date, document); err != nil {
+				return fmt.Errorf("failed to encode bulk document: %w", err)
+			}
+		}
 	}
 
-	fmt.Printf("Analyzing %d folder(s)...\n", len(folderPaths))
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write bulk file: %w", err)
+	}
+	return nil
+}
 
-	// Process folders concurrently
-	results := processFoldersConcurrently(folderPaths)
+// writeCSVFile writes date,count rows summed over all successful folders, sorted by date.
+// With byFolder each folder gets its own rows under a leading folder column instead. With
+// cumulative a running total column follows the count, and fillGaps adds zero rows for days
+// without entries, as in the text report.
+func writeCSVFile(path string, results []analyzer.FolderResult, byFolder, cumulative, fillGaps bool) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create CSV file: %w", err)
+	}
+	defer file.Close()
 
-	// Aggregate results
-	aggregateDateCountMap := make(map[string]int)
-	totalEntriesAcrossAllFolders := 0
-	successfulFolders := 0
+	writer := csv.NewWriter(file)
+	header := []string{"date", "count"}
+	if cumulative {
+		header = append(header, "cumulative")
+	}
+	// writeRows writes one row per date, after the leading columns
+	writeRows := func(leading []string, dateCountMap map[string]int) {
+		if !cumulative {
+			for _, date := range analyzer.SortedKeys(dateCountMap) {
+				writer.Write(append(leading, date, strconv.Itoa(dateCountMap[date])))
+			}
+			return
+		}
+		dates, runningTotals := cumulativeCounts(dateCountMap, fillGaps)
+		for i, date := range dates {
+			writer.Write(append(leading, date, strconv.Itoa(dateCountMap[date]), strconv.Itoa(runningTotals[i])))
+		}
+	}
+
+	if byFolder {
+		writer.Write(append([]string{"folder"}, header...))
+		for _, result := range results {
+			if result.Error != nil {
+				continue
+			}
+			writeRows([]string{result.DisplayName()}, result.DateCountMap)
+		}
+	} else {
+		dateCountMap := make(map[string]int)
+		for _, result := range results {
+			if result.Error != nil {
+				continue
+			}
+			for date, count := range result.DateCountMap {
+				dateCountMap[date] += count
+			}
+		}
+
+		writer.Write(header)
+		writeRows(nil, dateCountMap)
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write CSV file: %w", err)
+	}
+	return nil
+}
+
+// HTML chart geometry, in SVG user units
+const (
+	htmlChartWidth  = 800
+	htmlChartHeight = 300
+	htmlChartLeft   = 60 // room for the count labels
+	htmlChartBottom = 70 // room for the rotated date labels
+	htmlChartTop    = 20
+)
+
+// htmlReportTemplate renders writeHTMLFile's page. html/template escapes every value, including
+// folder names and dates inside the SVG.
+const htmlReportTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Pattern}} entries by date</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-top: 1em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; }
+td.count { text-align: right; }
+.bar { fill: #4a78c2; }
+.axis { stroke: #444; }
+.grid { stroke: #ddd; }
+svg text { font-size: 11px; fill: #444; }
+</style>
+</head>
+<body>
+<h1>{{.Pattern}} entries by date</h1>
+<p>{{.Total}} entries on {{len .Bars}} days across {{.SuccessfulFolders}} of {{len .Folders}} folder(s).</p>
+{{if .Bars}}
+<svg width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}" role="img" aria-label="Bar chart of entries per date">
+{{range .Ticks}}<line class="grid" x1="{{$.Left}}" y1="{{.Y}}" x2="{{$.Right}}" y2="{{.Y}}"/>
+<text x="{{$.Left}}" y="{{.Y}}" dx="-6" dy="4" text-anchor="end">{{.Value}}</text>
+{{end}}{{range .Bars}}<rect class="bar" x="{{printf "%.1f" .X}}" y="{{printf "%.1f" .Y}}" width="{{printf "%.1f" .Width}}" height="{{printf "%.1f" .Height}}"><title>{{.Date}}: {{.Count}}</title></rect>
+{{if .Labeled}}<text transform="translate({{printf "%.1f" .LabelX}},{{$.Baseline}}) rotate(-45)" dy="12" text-anchor="end">{{.Date}}</text>
+{{end}}{{end}}<line class="axis" x1="{{.Left}}" y1="{{.Baseline}}" x2="{{.Right}}" y2="{{.Baseline}}"/>
+<line class="axis" x1="{{.Left}}" y1="{{.Top}}" x2="{{.Left}}" y2="{{.Baseline}}"/>
+<text transform="translate(14,{{.MidY}}) rotate(-90)" text-anchor="middle">Entries</text>
+<text x="{{.MidX}}" y="{{.Height}}" dy="-4" text-anchor="middle">Date</text>
+</svg>
+{{end}}
+<table>
+<tr><th>Date</th><th>Entries</th></tr>
+{{range .Bars}}<tr><td>{{.Date}}</td><td class="count">{{.Count}}</td></tr>
+{{end}}</table>
+<h2>Folders</h2>
+<table>
+<tr><th>Folder</th><th>Entries</th></tr>
+{{range .Folders}}<tr><td>{{.DisplayName}}</td><td class="count">{{if .Error}}error: {{.Error}}{{else}}{{.TotalCount}}{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`
+
+// htmlBar is one date's bar in the HTML chart
+type htmlBar struct {
+	Date                string
+	Count               int
+	X, Y, Width, Height float64
+	LabelX              float64
+	Labeled             bool // only every few dates are labeled when there are many
+}
 
-	fmt.Println("\n" + strings.Repeat("=", 80))
-	fmt.Println("RESULTS BY FOLDER")
-	fmt.Println(strings.Repeat("=", 80))
+// htmlTick is a horizontal grid line with its count label
+type htmlTick struct {
+	Y     int
+	Value int
+}
 
+// writeHTMLFile writes a self-contained HTML page with a bar chart (inline SVG, no scripts) and a
+// table of the counts per date summed over all successful folders, plus each folder's total
+func writeHTMLFile(path string, results []analyzer.FolderResult, pattern string) error {
+	dateCountMap := make(map[string]int)
+	total, successfulFolders := 0, 0
 	for _, result := range results {
 		if result.Error != nil {
-			fmt.Printf("\n[ERROR] Folder: %s\n", result.FolderPath)
-			fmt.Printf("  Error: %v\n", result.Error)
 			continue
 		}
-
+		analyzer.MergeCounts(dateCountMap, result.DateCountMap)
+		total += result.TotalCount
 		successfulFolders++
-		fmt.Printf("\n[SUCCESS] Folder: %s\n", result.FolderPath)
+	}
+
+	// Bars are scaled to the busiest date, which reaches the top of the plot area
+	dates := analyzer.SortedKeys(dateCountMap)
+	maxCount := 0
+	for _, count := range dateCountMap {
+		maxCount = max(maxCount, count)
+	}
+	plotWidth := float64(htmlChartWidth - htmlChartLeft - 10)
+	plotHeight := float64(htmlChartHeight - htmlChartTop - htmlChartBottom)
+	baseline := htmlChartHeight - htmlChartBottom
+	labelEvery := max(1, len(dates)/20)
+
+	bars := make([]htmlBar, len(dates))
+	for i, date := range dates {
+		slot := plotWidth / float64(len(dates))
+		height := plotHeight * float64(dateCountMap[date]) / float64(max(maxCount, 1))
+		bars[i] = htmlBar{
+			Date:    date,
+			Count:   dateCountMap[date],
+			X:       htmlChartLeft + float64(i)*slot + slot*0.1,
+			Y:       float64(baseline) - height,
+			Width:   slot * 0.8,
+			Height:  height,
+			LabelX:  htmlChartLeft + (float64(i)+0.5)*slot,
+			Labeled: i%labelEvery == 0,
+		}
+	}
 
-		// Show per-file counts if verbose mode is enabled
-		if verbose && len(result.FileCountMap) > 0 {
-			fmt.Println("  Files:")
-			for fileName, count := range result.FileCountMap {
-				fmt.Printf("    - %s: %d entries\n", fileName, count)
+	// Up to four grid lines above the baseline; small maxima would repeat the same count
+	var ticks []htmlTick
+	for fraction := 0; fraction <= 4; fraction++ {
+		value := maxCount * fraction / 4
+		if len(ticks) > 0 && ticks[len(ticks)-1].Value == value {
+			continue
+		}
+		ticks = append(ticks, htmlTick{Y: baseline - int(plotHeight*float64(value)/float64(max(maxCount, 1))), Value: value})
+	}
+
+	page, err := template.New("report").Parse(htmlReportTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse HTML template: %w", err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create HTML file: %w", err)
+	}
+	defer file.Close()
+
+	err = page.Execute(file, map[string]any{
+		"Pattern":           pattern,
+		"Total":             total,
+		"SuccessfulFolders": successfulFolders,
+		"Folders":           results,
+		"Bars":              bars,
+		"Ticks":             ticks,
+		"Width":             htmlChartWidth,
+		"Height":            htmlChartHeight,
+		"Left":              htmlChartLeft,
+		"Right":             htmlChartWidth - 10,
+		"Top":               htmlChartTop,
+		"Baseline":          baseline,
+		"MidX":              htmlChartLeft + int(plotWidth)/2,
+		"MidY":              htmlChartTop + int(plotHeight)/2,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write HTML file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write HTML file: %w", err)
+	}
+	return nil
+}
+
+// writeParquetFile writes one row per (folder, date, hour) bucket, sorted for stable output
+func writeParquetFile(path string, results []analyzer.FolderResult) error {
+	var rows []ParquetRow
+	for _, result := range results {
+		if result.Error != nil {
+			continue
+		}
+
+		dates := analyzer.SortedKeys(result.DateHourlyData)
+
+		for _, date := range dates {
+			hourlyData := result.HourlyCounts(date)
+			for hour := 0; hour < 24; hour++ {
+				if count, ok := hourlyData[hour]; ok {
+					rows = append(rows, ParquetRow{
+						Folder: result.DisplayName(),
+						Date:   date,
+						Hour:   int32(hour),
+						Count:  int64(count),
+					})
+				}
 			}
 		}
+	}
 
-		// Show per-day statistics with average emails per hour if verbose mode is enabled
-		if verbose && len(result.DateCountMap) > 0 {
-			fmt.Println("  Per-Day Statistics:")
-			for date, count := range result.DateCountMap {
-				// Calculate average emails per hour for this date
-				avgPerHour := calculateAveragePerHour(result.DateHourlyData[date], count)
-				fmt.Printf("    - %s: %d entries (avg %.2f emails/hour)\n", date, count, avgPerHour)
+	if err := parquet.WriteFile(path, rows); err != nil {
+		return fmt.Errorf("failed to write parquet file: %w", err)
+	}
+	return nil
+}
+
+// histogramLevels are the bar heights used by renderHistogram, from empty to full
+var histogramLevels = []rune(" ▁▂▃▄▅▆▇█")
+
+// renderHistogram draws one block character per hour 00-23, scaled so the busiest hour is a full
+// block. Hours without entries are blank; any non-zero hour gets at least the lowest bar.
+func renderHistogram(hourlyData map[int]int) string {
+	_, peak := analyzer.PeakBucket(hourlyData, 24)
+
+	var bars strings.Builder
+	for hour := 0; hour < 24; hour++ {
+		level := 0
+		if count := hourlyData[hour]; count > 0 {
+			level = max(1, count*(len(histogramLevels)-1)/peak)
+		}
+		bars.WriteRune(histogramLevels[level])
+	}
+	return bars.String()
+}
+
+// expandFolderPattern expands a folder argument containing glob metacharacters (e.g. "C:\\Logs\\2024-*")
+// into the matching folders and zip archives, sorted. Literal paths are returned unchanged. A pattern
+// that matches nothing is reported, since dropping it silently would hide a typo.
+func expandFolderPattern(pattern string, logger *analyzer.WarningLogger) []string {
+	if !strings.ContainsAny(pattern, "*?[") {
+		return []string{pattern}
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		logger.Warnf(pattern, "", "invalid folder pattern %s: %v", pattern, err)
+		return nil
+	}
+
+	var folders []string
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && (info.IsDir() || analyzer.IsZipArchive(match)) {
+			folders = append(folders, match)
+		}
+	}
+	if len(folders) == 0 {
+		logger.Warnf(pattern, "", "no folders match %s", pattern)
+	}
+	return folders
+}
+
+// listFiles prints the absolute path of every file that would be scanned, one per line, sorted
+func listFiles(folderPaths []string, opts analyzer.Options) {
+	var paths []string
+	for _, folderPath := range folderPaths {
+		if analyzer.IsZipArchive(folderPath) {
+			entries, err := analyzer.ListZipEntries(folderPath, opts)
+			if err != nil {
+				opts.Logger.Warnf(folderPath, "", "%s: %v", folderPath, err)
+				continue
 			}
+			paths = append(paths, entries...)
+			continue
 		}
 
-		fmt.Printf("  Total '2FA - Email' entries: %d\n", result.TotalCount)
-		totalEntriesAcrossAllFolders += result.TotalCount
+		files, err := analyzer.DiscoverFiles(folderPath, opts)
+		if err != nil {
+			opts.Logger.Warnf(folderPath, "", "%s: %v", folderPath, err)
+			continue
+		}
 
-		// Aggregate dates
-		for date, count := range result.DateCountMap {
-			aggregateDateCountMap[date] += count
+		// Files over --max-file-size are left out, as the scan skips them unread
+		files, oversized := analyzer.SkipOversizedFiles(files, opts.MaxFileSize)
+		for _, filePath := range analyzer.SortedKeys(oversized) {
+			opts.Logger.Warnf(folderPath, filePath, "Skipping file %s: %d bytes is over --max-file-size", filePath, oversized[filePath])
+		}
+		for _, filePath := range expandZipArchives(folderPath, files, opts) {
+			if abs, err := filepath.Abs(filePath); err == nil {
+				filePath = abs
+			}
+			paths = append(paths, filePath)
 		}
 	}
 
-	// Print aggregate summary
-	if len(aggregateDateCountMap) == 0 {
-		fmt.Println("\n" + strings.Repeat("=", 80))
-		fmt.Println("No entries with '2FA - Email' found in any log files.")
-		return
+	sort.Strings(paths)
+	for _, filePath := range paths {
+		fmt.Println(filePath)
 	}
+}
 
-	distinctDays := len(aggregateDateCountMap)
-	average := float64(totalEntriesAcrossAllFolders) / float64(distinctDays)
+// expandZipArchives replaces each zip archive among a folder's files with its log entries, which
+// are what a scan reads. An archive that can't be listed is reported to opts.Logger and left out.
+func expandZipArchives(folderPath string, files []string, opts analyzer.Options) []string {
+	var expanded []string
+	for _, filePath := range files {
+		if !analyzer.IsZipArchive(filePath) {
+			expanded = append(expanded, filePath)
+			continue
+		}
+		entries, err := analyzer.ListZipEntries(filePath, opts)
+		if err != nil {
+			opts.Logger.Warnf(folderPath, filePath, "%s: %v", filePath, err)
+			continue
+		}
+		expanded = append(expanded, entries...)
+	}
+	return expanded
+}
 
-	fmt.Println("\n" + strings.Repeat("=", 80))
-	fmt.Println("AGGREGATE RESULTS (ALL FOLDERS)")
-	fmt.Println(strings.Repeat("=", 80))
+// printDryRun prints, per folder, the files a real run would scan and their total, without
+// reading any of them. Zip archives are opened only to list their entries.
+func printDryRun(folderPaths []string, opts analyzer.Options) {
+	totalFiles, scannableFolders := 0, 0
+	for _, folderPath := range folderPaths {
+		var files []string
+		var err error
+		if analyzer.IsZipArchive(folderPath) {
+			files, err = analyzer.ListZipEntries(folderPath, opts)
+		} else {
+			files, err = analyzer.DiscoverFiles(folderPath, opts)
+		}
+		if err != nil {
+			fmt.Printf("\n[ERROR] Folder: %s\n  Error: %v\n", folderPath, err)
+			continue
+		}
+		files, oversized := analyzer.SkipOversizedFiles(files, opts.MaxFileSize)
+		files = expandZipArchives(folderPath, files, opts)
 
-	fmt.Println("\n2FA - Email Entries by Date:")
-	for date, count := range aggregateDateCountMap {
-		fmt.Printf("  %s: %d entries\n", date, count)
+		fmt.Printf("\nFolder: %s (%d file(s))\n", folderPath, len(files))
+		for _, filePath := range files {
+			fmt.Printf("  %s\n", filePath)
+		}
+		for _, filePath := range analyzer.SortedKeys(oversized) {
+			fmt.Printf("  %s (skipped, %s is over --max-file-size)\n", filePath, formatBytes(oversized[filePath]))
+		}
+		totalFiles += len(files)
+		scannableFolders++
 	}
 
-	fmt.Println("\nSummary:")
-	fmt.Printf("  Total folders processed: %d\n", len(folderPaths))
-	fmt.Printf("  Successful folders: %d\n", successfulFolders)
-	fmt.Printf("  Total entries with '2FA - Email': %d\n", totalEntriesAcrossAllFolders)
-	fmt.Printf("  Total distinct days: %d\n", distinctDays)
-	fmt.Printf("  Average entries per day: %.2f\n", average)
+	fmt.Printf("\nDry run: %d file(s) in %d of %d folder(s) would be scanned\n", totalFiles, scannableFolders, len(folderPaths))
 }
 
-func printUsage() {
-	fmt.Println("Usage:")
-	fmt.Println("  analyze_logs [options] <folder_path1> [folder_path2] ...")
-	fmt.Println("  analyze_logs [options] --config <config_file>")
-	fmt.Println()
-	fmt.Println("Options:")
-	fmt.Println("  --verbose       Show detailed per-file statistics")
-	fmt.Println("  --config <file> Load folder paths from a JSON config file")
-	fmt.Println()
-	fmt.Println("Examples:")
-	fmt.Println("  analyze_logs C:\\Logs\\Folder1")
-	fmt.Println("  analyze_logs C:\\Logs\\Folder1 D:\\Logs\\Folder2 --verbose")
-	fmt.Println("  analyze_logs --config config.json --verbose")
-	fmt.Println("  analyze_logs \\\\\\server1\\share\\logs \\\\\\server2\\share\\logs")
+// printApproxDistinct prints the estimated distinct recipients per date and across all dates
+func printApproxDistinct(out io.Writer, sketches map[string]*analyzer.HyperLogLog, precision int) {
+	dates := analyzer.SortedKeys(sketches)
+
+	errorBound := analyzer.HyperLogLogErrorBound() * 100
+	overall := analyzer.NewHyperLogLog()
+
+	fmt.Fprintf(out, "\nApproximate Distinct Recipients by Date (±%s%%):\n", formatFloat(errorBound, precision))
+	for _, date := range dates {
+		fmt.Fprintf(out, "  %s: ~%d recipients\n", date, sketches[date].Estimate())
+		overall.Merge(sketches[date])
+	}
+	fmt.Fprintf(out, "  All dates: ~%d recipients\n", overall.Estimate())
 }
 
-func loadConfigFile(configPath string) ([]string, error) {
-	file, err := os.Open(configPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open config file: %w", err)
+// dayparts are the fixed named bins used by --daypart, each covering six hours
+var dayparts = []struct {
+	Name      string
+	StartHour int
+}{
+	{"night", 0},
+	{"morning", 6},
+	{"afternoon", 12},
+	{"evening", 18},
+}
+
+// daypartIndex returns the index in dayparts of the bin containing hour
+func daypartIndex(hour int) int {
+	return hour / 6
+}
+
+// printDayparts prints counts per daypart for each date and in total, derived from the hourly data
+func printDayparts(out io.Writer, results []analyzer.FolderResult) {
+	dateDayparts := make(map[string][]int)
+	for _, result := range results {
+		if result.Error != nil {
+			continue
+		}
+		for date := range result.DateHourlyData {
+			hourlyData := result.HourlyCounts(date)
+			if dateDayparts[date] == nil {
+				dateDayparts[date] = make([]int, len(dayparts))
+			}
+			for hour, count := range hourlyData {
+				dateDayparts[date][daypartIndex(hour)] += count
+			}
+		}
 	}
-	defer file.Close()
 
-	var config Config
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	totals := make([]int, len(dayparts))
+	ranges := make([]string, len(dayparts))
+	for i, part := range dayparts {
+		ranges[i] = fmt.Sprintf("%s %02d-%02d", part.Name, part.StartHour, part.StartHour+5)
 	}
+	fmt.Fprintf(out, "\nEntries by Daypart (%s):\n", strings.Join(ranges, ", "))
+	for _, date := range analyzer.SortedKeys(dateDayparts) {
+		fmt.Fprintf(out, "  %s: %s\n", date, formatDayparts(dateDayparts[date]))
+		for i, count := range dateDayparts[date] {
+			totals[i] += count
+		}
+	}
+	fmt.Fprintf(out, "  All dates: %s\n", formatDayparts(totals))
+}
 
-	if len(config.Folders) == 0 {
-		return nil, fmt.Errorf("no folders specified in config file")
+// printWeekdays prints the total entries per day of the week, Monday first, and the average over
+// the dates with entries that fell on that weekday
+func printWeekdays(out io.Writer, dateCountMap map[string]int, precision int) {
+	var totals, days [7]int // indexed by time.Weekday, Sunday = 0
+	for dateStr, count := range dateCountMap {
+		date, err := time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			continue
+		}
+		totals[date.Weekday()] += count
+		days[date.Weekday()]++
 	}
 
-	return config.Folders, nil
+	fmt.Fprintln(out, "\nEntries by Weekday:")
+	for i := 0; i < 7; i++ {
+		weekday := time.Weekday((i + 1) % 7) // Monday first, Sunday last
+		average := 0.0
+		if days[weekday] > 0 {
+			average = float64(totals[weekday]) / float64(days[weekday])
+		}
+		fmt.Fprintf(out, "  %-10s %d entries (avg %s over %d days)\n", weekday.String()+":", totals[weekday], formatFloat(average, precision), days[weekday])
+	}
 }
 
-func processFoldersConcurrently(folderPaths []string) []FolderResult {
-	var wg sync.WaitGroup
-	results := make([]FolderResult, len(folderPaths))
+// printMinuteOfHour prints the distribution of entries over minutes 00-59 and how strongly the
+// busiest minute stands out; a peak far above an even spread points at batched sending
+func printMinuteOfHour(out io.Writer, minuteCounts [60]int, precision int) {
+	total := 0
+	peakMinute := 0
+	for minute, count := range minuteCounts {
+		total += count
+		if count > minuteCounts[peakMinute] {
+			peakMinute = minute
+		}
+	}
+
+	fmt.Fprintln(out, "\nEntries by Minute of Hour:")
+	if total == 0 {
+		fmt.Fprintln(out, "  No timestamps with a parseable minute")
+		return
+	}
 
-	for i, folderPath := range folderPaths {
-		wg.Add(1)
-		go func(index int, path string) {
-			defer wg.Done()
-			results[index] = processFolder(path)
-		}(i, folderPath)
+	for minute, count := range minuteCounts {
+		share := float64(count) / float64(total) * 100
+		fmt.Fprintf(out, "  :%02d %d entries (%s%%)\n", minute, count, formatFloat(share, precision))
 	}
 
-	wg.Wait()
-	return results
+	// An even spread would put total/60 entries in every minute
+	ratio := float64(minuteCounts[peakMinute]) / (float64(total) / 60)
+	fmt.Fprintf(out, "  Peak minute: :%02d with %d entries (%sx an even spread)\n",
+		peakMinute, minuteCounts[peakMinute], formatFloat(ratio, precision))
 }
 
-func calculateAveragePerHour(hourlyData map[int]int, totalCount int) float64 {
-	if len(hourlyData) == 0 {
-		return 0.0
+// formatDayparts renders per-daypart counts as "night 12, morning 40, ..."
+func formatDayparts(counts []int) string {
+	parts := make([]string, len(dayparts))
+	for i, part := range dayparts {
+		parts[i] = fmt.Sprintf("%s %d", part.Name, counts[i])
 	}
+	return strings.Join(parts, ", ")
+}
 
-	// Find min and max hour to determine the time span
-	minHour, maxHour := 23, 0
-	for hour := range hourlyData {
-		if hour < minHour {
-			minHour = hour
+// printPatternBreakdown lists each pattern's total and its counts per date (or per --rollup period),
+// in the order the patterns were given
+func printPatternBreakdown(out io.Writer, patterns []string, patternDateCounts map[string]map[string]int, rollup string) {
+	fmt.Fprintln(out, "\nEntries by Pattern:")
+	for _, pattern := range patterns {
+		periodCounts := patternDateCounts[pattern]
+		if rollup != "" {
+			periodCounts = rollupDates(periodCounts, rollup)
+		}
+		total := 0
+		for _, count := range periodCounts {
+			total += count
 		}
-		if hour > maxHour {
-			maxHour = hour
+		fmt.Fprintf(out, "  '%s': %d entries\n", pattern, total)
+		for _, period := range analyzer.SortedKeys(periodCounts) {
+			fmt.Fprintf(out, "    %s: %d entries\n", period, periodCounts[period])
 		}
 	}
+}
+
+// printResultBreakdown prints success/failure/unknown counts per date and in total
+func printResultBreakdown(out io.Writer, dateResultCounts map[string]map[string]int, precision int) {
+	dates := analyzer.SortedKeys(dateResultCounts)
+
+	totals := make(map[string]int)
+	fmt.Fprintln(out, "\nResults by Date:")
+	for _, date := range dates {
+		fmt.Fprintf(out, "  %s: %s\n", date, formatResultCounts(dateResultCounts[date], precision))
+		for bucket, count := range dateResultCounts[date] {
+			totals[bucket] += count
+		}
+	}
+	fmt.Fprintf(out, "  All dates: %s\n", formatResultCounts(totals, precision))
+}
 
-	// Calculate hours span (inclusive)
-	hoursSpan := maxHour - minHour + 1
-	if hoursSpan <= 0 {
-		hoursSpan = 1
+// formatResultCounts renders result buckets with the success rate of the classified entries
+func formatResultCounts(counts map[string]int, precision int) string {
+	success, failure, unknown := counts[analyzer.ResultSuccess], counts[analyzer.ResultFailure], counts[analyzer.ResultUnknown]
+	rate := "n/a"
+	if success+failure > 0 {
+		rate = formatFloat(float64(success)/float64(success+failure)*100, precision) + "%"
 	}
+	return fmt.Sprintf("%d success, %d failure, %d unknown (success rate %s)", success, failure, unknown, rate)
+}
 
-	return float64(totalCount) / float64(hoursSpan)
+// GenOptions controls the synthetic log fixtures written by the gen subcommand
+type GenOptions struct {
+	OutputFolder string
+	Files        int
+	LinesPerFile int
+	Rate         float64
+	Start        time.Time
+	End          time.Time
+	Seed         int64
+	EdgeCases    bool
 }
 
-func processFolder(folderPath string) FolderResult {
-	result := FolderResult{
-		FolderPath:     folderPath,
-		DateCountMap:   make(map[string]int),
-		FileCountMap:   make(map[string]int),
-		DateHourlyData: make(map[string]map[int]int),
+// runGen parses the gen subcommand arguments and writes the fixture folder
+func runGen(args []string) error {
+	opts := GenOptions{
+		Files:        5,
+		LinesPerFile: 500,
+		Rate:         0.6,
+		Start:        time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		End:          time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+		Seed:         1,
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--edge-cases" {
+			opts.EdgeCases = true
+			continue
+		}
+		if !strings.HasPrefix(arg, "--") {
+			opts.OutputFolder = arg
+			continue
+		}
+		if i+1 >= len(args) {
+			return fmt.Errorf("%s flag requires a value", arg)
+		}
+		value := args[i+1]
+		i++ // Skip next argument (flag value)
+
+		var err error
+		switch arg {
+		case "--files":
+			_, err = fmt.Sscanf(value, "%d", &opts.Files)
+		case "--lines":
+			_, err = fmt.Sscanf(value, "%d", &opts.LinesPerFile)
+		case "--rate":
+			_, err = fmt.Sscanf(value, "%g", &opts.Rate)
+		case "--start":
+			opts.Start, err = time.Parse("2006-01-02", value)
+		case "--end":
+			opts.End, err = time.Parse("2006-01-02", value)
+		case "--seed":
+			_, err = fmt.Sscanf(value, "%d", &opts.Seed)
+		default:
+			return fmt.Errorf("unknown gen option %s", arg)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid value %q for %s: %w", value, arg, err)
+		}
+	}
+
+	if opts.OutputFolder == "" {
+		return fmt.Errorf("no output folder provided")
+	}
+	if opts.Files < 1 || opts.LinesPerFile < 0 {
+		return fmt.Errorf("--files must be at least 1 and --lines must not be negative")
+	}
+	if opts.Rate < 0 || opts.Rate > 1 {
+		return fmt.Errorf("--rate must be between 0 and 1")
+	}
+	if opts.End.Before(opts.Start) {
+		return fmt.Errorf("--end must not be before --start")
 	}
 
-	// Read all .txt files in the folder
-	files, err := filepath.Glob(filepath.Join(folderPath, "*.txt"))
+	matches, err := generateFixtures(opts)
 	if err != nil {
-		result.Error = fmt.Errorf("error reading folder: %w", err)
-		return result
+		return err
 	}
 
-	if len(files) == 0 {
-		result.Error = fmt.Errorf("no .txt files found in folder")
-		return result
+	fmt.Printf("Generated %d file(s) in %s with %d countable '2FA - Email' entries\n", opts.Files, opts.OutputFolder, matches)
+	return nil
+}
+
+// generateFixtures writes the log files and returns how many entries a scan should count
+func generateFixtures(opts GenOptions) (int, error) {
+	if err := os.MkdirAll(opts.OutputFolder, 0o755); err != nil {
+		return 0, fmt.Errorf("failed to create output folder: %w", err)
 	}
 
-	// Process each file
-	for _, filePath := range files {
-		file, err := os.Open(filePath)
-		if err != nil {
-			// Log error but continue with other files
-			fmt.Printf("Warning: Error opening file %s: %v\n", filePath, err)
-			continue
-		}
+	rng := rand.New(rand.NewSource(opts.Seed))
+	days := int(opts.End.Sub(opts.Start).Hours()/24) + 1
+	countable := 0
 
-		fileName := filepath.Base(filePath)
-		fileCount := 0
-
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			line := scanner.Text()
-
-			// Check if line contains "2FA - Email"
-			if strings.Contains(line, "2FA - Email") {
-				// Extract the date and time from the line (format: YYYY-MM-DD HH:MM:SS)
-				parts := strings.Fields(line)
-				if len(parts) >= 2 {
-					dateStr := parts[0]
-					timeStr := parts[1]
-
-					// Parse date to ensure it's valid
-					_, err := time.Parse("2006-01-02", dateStr)
-					if err == nil {
-						result.DateCountMap[dateStr]++
-						fileCount++
-						result.TotalCount++
-
-						// Extract hour from time string (HH:MM:SS)
-						timeParts := strings.Split(timeStr, ":")
-						if len(timeParts) >= 1 {
-							var hour int
-							_, err := fmt.Sscanf(timeParts[0], "%d", &hour)
-							if err == nil && hour >= 0 && hour <= 23 {
-								// Initialize map for this date if needed
-								if result.DateHourlyData[dateStr] == nil {
-									result.DateHourlyData[dateStr] = make(map[int]int)
-								}
-								result.DateHourlyData[dateStr][hour]++
-							}
-						}
-					}
-				}
+	for i := 0; i < opts.Files; i++ {
+		date := opts.Start.AddDate(0, 0, i%days)
+		fileName := fmt.Sprintf("log_%s_%03d.txt", date.Format("2006-01-02"), i+1)
+
+		// Edge cases rotate across files so a small run still covers each of them
+		lineEnding := "\n"
+		withBOM := false
+		if opts.EdgeCases {
+			withBOM = i%3 == 0
+			if i%3 == 1 {
+				lineEnding = "\r\n"
 			}
 		}
 
-		if err := scanner.Err(); err != nil {
-			fmt.Printf("Warning: Error reading file %s: %v\n", filePath, err)
+		// Timestamps are sorted so the files look like real append-only logs
+		seconds := make([]int, opts.LinesPerFile)
+		for j := range seconds {
+			seconds[j] = rng.Intn(24 * 60 * 60)
 		}
+		sort.Ints(seconds)
 
-		result.FileCountMap[fileName] = fileCount
-		file.Close()
+		var builder strings.Builder
+		if withBOM {
+			builder.WriteString("\ufeff")
+		}
+		for j, second := range seconds {
+			timestamp := date.Add(time.Duration(second) * time.Second)
+			dateStr := timestamp.Format("2006-01-02")
+			sessionID := rng.Intn(40000) + 1
+
+			if rng.Float64() >= opts.Rate {
+				fmt.Fprintf(&builder, "%s %s [INFO] User authentication process: 2FA Validate - Session ID: %d - Status: Success%s",
+					dateStr, timestamp.Format("15:04:05"), sessionID, lineEnding)
+				continue
+			}
+
+			// The scanner strips a leading BOM, so the entry right after it still counts; it is
+			// never corrupted, so that a BOM file always exercises that path
+			bomLine := withBOM && j == 0
+			corrupt := opts.EdgeCases && !bomLine && rng.Float64() < 0.01
+			if corrupt {
+				dateStr = fmt.Sprintf("%d-13-%02d", timestamp.Year(), timestamp.Day())
+			} else {
+				countable++
+			}
+			fmt.Fprintf(&builder, "%s %s [INFO] User authentication process: 2FA - Email - Session ID: %d - Status: Success%s",
+				dateStr, timestamp.Format("15:04:05"), sessionID, lineEnding)
+		}
+
+		filePath := filepath.Join(opts.OutputFolder, fileName)
+		if err := os.WriteFile(filePath, []byte(builder.String()), 0o644); err != nil {
+			return countable, fmt.Errorf("failed to write %s: %w", filePath, err)
+		}
 	}
 
-	return result
+	return countable, nil
 }
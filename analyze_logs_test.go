@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestExpandPath(t *testing.T) {
+	home := "/home/ops"
+	homeVar := "HOME"
+	if runtime.GOOS == "windows" {
+		home, homeVar = `C:\Users\ops`, "USERPROFILE"
+	}
+	t.Setenv(homeVar, home)
+	t.Setenv("LOGDIR", "/var/log/mail")
+	// Setenv restores the variable after the test, so it can be unset safely
+	t.Setenv("MAILCHECKER_UNSET_VAR", "")
+	os.Unsetenv("MAILCHECKER_UNSET_VAR")
+
+	type testCase struct {
+		name string
+		path string
+		want string
+	}
+	tests := []testCase{
+		{name: "tilde", path: "~", want: home},
+		{name: "tilde folder", path: "~/x", want: home + "/x"},
+		{name: "tilde inside a name", path: "/srv/~x", want: "/srv/~x"},
+		{name: "$HOME", path: "$" + homeVar + "/x", want: home + "/x"},
+		{name: "${VAR}", path: "${LOGDIR}/x", want: "/var/log/mail/x"},
+		{name: "$VAR inside a path", path: "/mnt$LOGDIR/x", want: "/mnt/var/log/mail/x"},
+		{name: "undefined $VAR", path: "$MAILCHECKER_UNSET_VAR/x", want: "$MAILCHECKER_UNSET_VAR/x"},
+		{name: "undefined ${VAR}", path: "${MAILCHECKER_UNSET_VAR}/x", want: "${MAILCHECKER_UNSET_VAR}/x"},
+	}
+	if runtime.GOOS == "windows" {
+		tests = append(tests, []testCase{
+			{name: "tilde backslash", path: `~\x`, want: home + `\x`},
+			{name: "%VAR%", path: `%LOGDIR%\x`, want: `/var/log/mail\x`},
+			{name: "undefined %VAR%", path: `%MAILCHECKER_UNSET_VAR%\x`, want: `%MAILCHECKER_UNSET_VAR%\x`},
+			{name: "admin share", path: `\\server\C$\Logs`, want: `\\server\C$\Logs`},
+		}...)
+	} else {
+		tests = append(tests, []testCase{
+			// cmd.exe syntax and backslashes are plain characters outside Windows
+			{name: "%VAR% left alone", path: "%LOGDIR%/x", want: "%LOGDIR%/x"},
+			{name: "tilde backslash left alone", path: `~\x`, want: `~\x`},
+		}...)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expandPath(tt.path); got != tt.want {
+				t.Errorf("expandPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,574 @@
+// Package analyzer counts log lines matching a pattern per date and hour, across folders of log
+// files. It is the engine behind the analyze_logs command and can be embedded in other programs:
+//
+//	results := analyzer.ProcessFolders(ctx, []string{"/var/log/mail"}, analyzer.Options{
+//		Patterns:   []string{"2FA - Email"},
+//		Extensions: []string{"txt", "log"},
+//		Since:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+//		Workers:    4,
+//	})
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// FolderResult stores the results for a single folder
+type FolderResult struct {
+	FolderPath     string
+	Patterns       []string       // substrings that lines were matched against; a line counts if it contains any
+	Regex          *regexp.Regexp // set instead of Pattern when matching with --regex
+	IgnoreCase     bool           // Pattern is matched case-insensitively (--ignore-case)
+	Label          string         // human-friendly name from the config, shown instead of the path
+	DateCountMap   map[string]int
+	FileCountMap   map[string]int
+	DateHourlyData map[string]map[int]int // date -> hour -> count, or date -> minute of day -> count with MinuteBuckets
+	MinuteBuckets  bool                   // DateHourlyData is keyed by minute of the day, 0-1439 (--granularity minute)
+	ExcludedHours  [24]bool               // hours dropped by --exclude-hours, left out of per-hour averages
+	// DateUnknownHour counts entries per date whose hour could not be parsed, so they are
+	// in DateCountMap but not in DateHourlyData
+	DateUnknownHour map[string]int
+	TotalCount      int
+	Error           error
+
+	// FileTimeRanges holds the earliest and latest timestamp counted from each file. Entries whose
+	// time of day could not be parsed are counted but leave the range alone.
+	FileTimeRanges map[string]TimeRange
+
+	// OversizedFiles holds the size of each file skipped unread because it exceeds --max-file-size
+	OversizedFiles map[string]int64
+
+	// CappedFiles lists files whose scan stopped at --max-matches-per-file
+	CappedFiles map[string]bool
+
+	// AbortedFiles lists files abandoned because their sampled parse error rate exceeded --max-error-rate
+	AbortedFiles map[string]bool
+
+	// OverlappingFiles holds pairs of files whose matched date ranges overlap (only with --check-overlap)
+	OverlappingFiles [][2]string
+
+	// DuplicateCount is the number of entries collapsed because their event ID was already seen that day
+	DuplicateCount int
+
+	// PatternDateCounts counts entries per pattern and date when several patterns are matched in one pass.
+	// A line containing more than one of the patterns counts toward each of them.
+	PatternDateCounts map[string]map[string]int
+
+	// LinesScanned and BytesScanned measure the input read from all files, after decompression
+	LinesScanned int64
+	BytesScanned int64
+
+	// Duration is the wall-clock time ProcessFolders spent on the folder, up to --folder-timeout
+	Duration time.Duration
+
+	// SkippedCount is the number of matching lines dropped because no valid date could be parsed from them
+	SkippedCount int
+
+	// SkippedSamples keeps the first few skipped lines, for spotting a changed log format in verbose output
+	SkippedSamples []string
+
+	// DateResultCounts splits entries into success/failure/unknown per date (only with --result-field/--result-regex)
+	DateResultCounts map[string]map[string]int
+
+	// MinuteOfHourCounts counts entries by the minute component of their timestamp (only with --by-minute-of-hour)
+	MinuteOfHourCounts [60]int
+
+	// Entries holds every counted line (only when collected for --es-bulk line mode)
+	Entries []MatchedEntry
+
+	// ScheduleAligned and ScheduleOff split timestamped entries by whether they fall within
+	// the tolerance of a --schedule activation
+	ScheduleAligned int
+	ScheduleOff     int
+
+	// ClientCountMap counts entries per client value (only with --client-regex)
+	ClientCountMap map[string]int
+
+	// DateRecipientSketch estimates distinct recipients per date (only with --approx-distinct)
+	DateRecipientSketch map[string]*HyperLogLog
+
+	// Recipients is the exact set of email addresses found on counted lines (not kept with
+	// --approx-distinct, which estimates the count from DateRecipientSketch instead)
+	Recipients map[string]bool
+
+	// DomainCountMap counts entries per recipient domain (the part after '@')
+	DomainCountMap map[string]int
+}
+
+// DistinctRecipients is the number of different email addresses the folder's entries were sent
+// to, estimated across all dates when only the --approx-distinct sketches were kept
+func (r FolderResult) DistinctRecipients() int {
+	if len(r.Recipients) == 0 && len(r.DateRecipientSketch) > 0 {
+		overall := NewHyperLogLog()
+		for _, sketch := range r.DateRecipientSketch {
+			overall.Merge(sketch)
+		}
+		return int(overall.Estimate())
+	}
+	return len(r.Recipients)
+}
+
+// MarshalJSON writes the folder's counts for --json, with Error as a string and the
+// scan-internal detail (hourly data, sketches, collected lines) left out
+func (r FolderResult) MarshalJSON() ([]byte, error) {
+	folder := JSONFolder{
+		Folder:     r.FolderPath,
+		Label:      r.Label,
+		Pattern:    DescribeMatch(r.Patterns, r.Regex, false),
+		TotalCount: r.TotalCount,
+		Skipped:    r.SkippedCount,
+		Recipients: r.DistinctRecipients(),
+		ByPattern:  r.PatternTotals(),
+		Dates:      r.DateCountMap,
+		Files:      r.FileCountMap,
+	}
+	if r.Error != nil {
+		folder.Error = r.Error.Error()
+	}
+	return json.Marshal(folder)
+}
+
+// JSONFolder is one folder's entry in the --json report
+type JSONFolder struct {
+	Folder     string         `json:"folder"`
+	Label      string         `json:"label,omitempty"`
+	Pattern    string         `json:"pattern"`
+	ByPattern  map[string]int `json:"by_pattern,omitempty"`
+	TotalCount int            `json:"total_count"`
+	Skipped    int            `json:"skipped_lines"`
+	Recipients int            `json:"distinct_recipients"`
+	Dates      map[string]int `json:"dates"`
+	Files      map[string]int `json:"files"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// DefaultPattern is the text counted when no --pattern is given
+const DefaultPattern = "2FA - Email"
+
+// DefaultMaxLineBytes is the longest line scanned when no --max-line-bytes is given
+const DefaultMaxLineBytes = 4 * 1024 * 1024
+
+// maxSkippedSamples is how many skipped lines a folder keeps for verbose output
+const maxSkippedSamples = 3
+
+// ErrCancelled is the folder error for scans cut short by Ctrl+C
+var ErrCancelled = errors.New("cancelled")
+
+// Options controls how each folder is scanned
+type Options struct {
+	Patterns        []string          // substrings a line must contain one of to be counted
+	Regex           *regexp.Regexp    // counted lines must match this instead of containing Pattern
+	FolderPatterns  map[string]string // per-folder pattern overrides from the config, by folder path
+	IgnoreCase      bool              // match Pattern regardless of case; Regex is compiled with (?i) instead
+	ApproxDistinct  bool
+	IDRegex         *regexp.Regexp // first capture group identifies an event for de-duplication
+	Sequential      bool           // process folders one at a time for deterministic output order
+	Strict          bool           // fail the folder on the first matching line without a valid date
+	Workers         int            // folders processed at once, 0 for one per CPU
+	FileWorkers     int            // files of one folder scanned at once, 0 for one per CPU
+	FolderTimeout   time.Duration  // give up on a folder that takes longer than this, 0 waits indefinitely
+	Retries         int            // times to retry opening a file after a transient error, 0 for none
+	RetryDelay      time.Duration  // wait before the first retry, doubled for each one after
+	Progress        bool           // report each finished folder on Status
+	Status          io.Writer      // progress and --follow updates (stderr for the command), nil for none
+	TimestampLayout string         // Go reference layout to find each line's timestamp, empty for leading "date time" fields
+	Location        *time.Location // convert timestamps into this zone before bucketing (--tz), nil keeps them as written
+	Since           time.Time      // skip entries dated before this day, zero for no lower bound
+	Until           time.Time      // skip entries dated after this day, zero for no upper bound
+	Recursive       bool           // scan log files in subfolders too, keyed by path relative to the folder
+	FollowSymlinks  bool           // with Recursive, also descend into symlinked subfolders
+	Extensions      []string       // file extensions to scan, without the dot
+	Include         []string       // base-name globs a file must match to be scanned, empty for all
+	Exclude         []string       // base-name globs that skip a file, even one matching Include
+	ResultRegex     *regexp.Regexp // first capture group holds the result code to classify
+	MaxMatches      int            // stop scanning a file after this many matches, 0 for no limit
+	MaxLineBytes    int            // longer lines are skipped with a warning
+	MaxFileSize     int64          // files larger than this many bytes are skipped unread, 0 for no limit
+	CheckOverlap    bool           // warn when files in a folder cover overlapping date ranges
+	ClientRegex     *regexp.Regexp // first capture group holds the client (e.g. "ios-app/3.2")
+	HourlyTopK      int            // keep hourly detail only for the K busiest dates, 0 keeps all
+	ByMinuteOfHour  bool           // count entries by minute of the hour across all dates
+	MinuteBuckets   bool           // bucket DateHourlyData by minute of the day instead of by hour
+	ExcludeHours    [24]bool       // hours of the day whose entries are dropped (--exclude-hours)
+	MaxErrorRate    float64        // abandon a file whose sampled parse error rate exceeds this, 0 disables
+	ErrorSample     int            // matching lines sampled per file before applying MaxErrorRate
+	CollectEntries  bool           // keep every counted line on FolderResult.Entries
+	OnEntry         EntryFunc      // called for each counted line as it is found, from several goroutines at once
+	LinePrefix      string         // skip lines that don't start with this prefix before pattern matching
+	Schedule        cron.Schedule  // count entries near these activations as scheduled, nil disables
+	ScheduleWindow  time.Duration  // how far from an activation an entry may be and still count as scheduled
+	Cache           *Cache         // reuse unchanged files' results from earlier runs (--cache), nil to always scan
+	Logger          *WarningLogger
+}
+
+// withDefaults fills in what a zero Options leaves unset: the default pattern, .txt files and
+// the default line length limit
+func (o Options) withDefaults() Options {
+	if len(o.Patterns) == 0 && o.Regex == nil {
+		o.Patterns = []string{DefaultPattern}
+	}
+	if len(o.Extensions) == 0 {
+		o.Extensions = []string{"txt"}
+	}
+	if o.MaxLineBytes <= 0 {
+		o.MaxLineBytes = DefaultMaxLineBytes
+	}
+	return o
+}
+
+// EntryFunc receives a counted line of the folder at folderPath as soon as it is found
+type EntryFunc func(folderPath string, entry MatchedEntry)
+
+// MatchedEntry is a single counted log line
+type MatchedEntry struct {
+	File string
+	Date string
+	Hour int // -1 when the hour could not be parsed
+	Line string
+}
+
+// DisplayName is the folder's config label if it has one, otherwise its path
+func (r FolderResult) DisplayName() string {
+	if r.Label != "" {
+		return r.Label
+	}
+	return r.FolderPath
+}
+
+// PatternTotals sums PatternDateCounts over all dates, or returns nil for a single-pattern scan
+func (r FolderResult) PatternTotals() map[string]int {
+	if len(r.PatternDateCounts) == 0 {
+		return nil
+	}
+	totals := make(map[string]int)
+	for pattern, dates := range r.PatternDateCounts {
+		for _, count := range dates {
+			totals[pattern] += count
+		}
+	}
+	return totals
+}
+
+// HourlyCounts returns a date's counts by hour, folding minute buckets into their hours
+func (r FolderResult) HourlyCounts(date string) map[int]int {
+	bucketData := r.DateHourlyData[date]
+	if !r.MinuteBuckets || bucketData == nil {
+		return bucketData
+	}
+	hourly := make(map[int]int)
+	for minute, count := range bucketData {
+		hourly[minute/60] += count
+	}
+	return hourly
+}
+
+// IsExcludedBucket reports whether a DateHourlyData bucket falls in an hour dropped by --exclude-hours
+func (r FolderResult) IsExcludedBucket(bucket int) bool {
+	if r.MinuteBuckets {
+		bucket /= 60
+	}
+	return r.ExcludedHours[bucket]
+}
+
+// MatchesLine reports whether a log line is one this folder counts
+func (r FolderResult) MatchesLine(line string) bool {
+	if r.Regex != nil {
+		return r.Regex.MatchString(line)
+	}
+	if r.IgnoreCase {
+		line = strings.ToLower(line)
+	}
+	for _, pattern := range r.Patterns {
+		if r.containsPattern(line, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsPattern reports whether a line contains one pattern. With IgnoreCase the line must
+// already be lower case.
+func (r FolderResult) containsPattern(line, pattern string) bool {
+	if r.IgnoreCase {
+		pattern = strings.ToLower(pattern)
+	}
+	return strings.Contains(line, pattern)
+}
+
+// Matches reports whether a user-supplied name refers to this folder, by label or by cleaned path
+func (r FolderResult) Matches(name string) bool {
+	return (r.Label != "" && name == r.Label) || filepath.Clean(r.FolderPath) == filepath.Clean(name)
+}
+
+// ProcessFolders scans each folder with opts, at most opts.Workers at a time, and returns the
+// results in the order of folderPaths. Folders not started before ctx is cancelled get ErrCancelled.
+func ProcessFolders(ctx context.Context, folderPaths []string, opts Options) []FolderResult {
+	opts = opts.withDefaults()
+	results := make([]FolderResult, len(folderPaths))
+
+	// Status is stderr for the command, so progress never mixes into the report or --json output
+	var completed atomic.Int64
+	reportProgress := func() {
+		done := completed.Add(1)
+		if opts.Progress && opts.Status != nil {
+			fmt.Fprintf(opts.Status, "Progress: completed %d/%d folders\n", done, len(folderPaths))
+		}
+	}
+
+	// Sequential mode trades speed for a fully reproducible warning order
+	if opts.Sequential {
+		for i, folderPath := range folderPaths {
+			results[i] = processFolderWithTimeout(ctx, folderPath, opts)
+			reportProgress()
+		}
+		return results
+	}
+
+	var wg sync.WaitGroup
+
+	// The semaphore caps how many folders are open at once, so large configs don't
+	// flood file servers with hundreds of simultaneous scans
+	workers := opts.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	semaphore := make(chan struct{}, workers)
+
+	for i, folderPath := range folderPaths {
+		wg.Add(1)
+		go func(index int, path string) {
+			defer wg.Done()
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				results[index] = newFolderResult(path, opts)
+				results[index].Error = ErrCancelled
+				return
+			}
+			results[index] = processFolderWithTimeout(ctx, path, opts)
+			reportProgress()
+		}(i, folderPath)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// processFolderWithTimeout runs ProcessFolder, failing the folder once opts.FolderTimeout has
+// passed. A scan blocked in a read on a hung network mount never sees its context, so it is left
+// behind rather than waited for; its result is discarded.
+func processFolderWithTimeout(ctx context.Context, folderPath string, opts Options) (result FolderResult) {
+	defer func(start time.Time) {
+		result.Duration = time.Since(start)
+	}(time.Now())
+
+	if opts.FolderTimeout <= 0 {
+		return ProcessFolder(ctx, folderPath, opts)
+	}
+
+	folderCtx, cancel := context.WithTimeout(ctx, opts.FolderTimeout)
+	defer cancel()
+	done := make(chan FolderResult, 1)
+	go func() {
+		done <- ProcessFolder(folderCtx, folderPath, opts)
+	}()
+
+	timeoutErr := fmt.Errorf("timeout after %s", opts.FolderTimeout)
+	select {
+	case result := <-done:
+		// The scan noticed the deadline between lines and stopped as if cancelled
+		if result.Error == ErrCancelled && ctx.Err() == nil {
+			result.Error = timeoutErr
+		}
+		return result
+	case <-folderCtx.Done():
+		// Ctrl+C rather than the timeout: scans stop at their next line, as without a timeout
+		if ctx.Err() != nil {
+			return <-done
+		}
+		result := newFolderResult(folderPath, opts)
+		result.Error = timeoutErr
+		return result
+	}
+}
+
+// ProcessFolder scans the log files of one folder, zip archive or single file. Problems that
+// stop the scan are returned in FolderResult.Error; per-file problems go to opts.Logger, so a
+// caller can capture them with NewWarningLogger or drop them with a nil Logger.
+func ProcessFolder(ctx context.Context, folderPath string, opts Options) FolderResult {
+	opts = opts.withDefaults()
+	// Zip archives are treated as folders of log files
+	if IsZipArchive(folderPath) {
+		return processZipArchive(ctx, folderPath, opts)
+	}
+
+	result := newFolderResult(folderPath, opts)
+
+	files, err := DiscoverFiles(folderPath, opts)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+
+	// Process each file
+	scan := newFolderScan(ctx, &result, opts)
+	files, oversized := SkipOversizedFiles(files, opts.MaxFileSize)
+	for _, filePath := range SortedKeys(oversized) {
+		opts.Logger.Warnf(folderPath, filePath, "Skipping file %s: %d bytes is over --max-file-size", filePath, oversized[filePath])
+		result.OversizedFiles[scan.fileName(filePath)] = oversized[filePath]
+	}
+	if workers := fileWorkers(opts); workers > 1 && len(files) > 1 {
+		scan.scanFilesConcurrently(files, workers)
+	} else {
+		for _, filePath := range files {
+			if ctx.Err() != nil {
+				result.Error = ErrCancelled
+				break
+			}
+			if opts.Cache != nil {
+				scan.merge(scan.scanPartial(filePath))
+			} else {
+				scan.scanFolderFile(filePath)
+			}
+			if result.Error != nil {
+				break
+			}
+		}
+	}
+	scan.finish()
+
+	return result
+}
+
+// PeakBucket returns the busiest of buckets 0 to n-1 (hours or minutes of the day) and its
+// count; ties go to the earliest bucket
+func PeakBucket(bucketData map[int]int, n int) (bucket, count int) {
+	for candidate := 0; candidate < n; candidate++ {
+		if bucketData[candidate] > count {
+			bucket, count = candidate, bucketData[candidate]
+		}
+	}
+	return bucket, count
+}
+
+// AveragePerBucket averages a day's count over the span of hours (or minutes) from its
+// first to its last busy bucket, not counting buckets for which excluded returns true
+func AveragePerBucket(bucketData map[int]int, totalCount int, excluded func(bucket int) bool) float64 {
+	if len(bucketData) == 0 {
+		return 0.0
+	}
+
+	// Find min and max bucket to determine the time span
+	minBucket, maxBucket := math.MaxInt, 0
+	for bucket := range bucketData {
+		if bucket < minBucket {
+			minBucket = bucket
+		}
+		if bucket > maxBucket {
+			maxBucket = bucket
+		}
+	}
+
+	// Calculate bucket span (inclusive), without excluded buckets
+	bucketSpan := 0
+	for bucket := minBucket; bucket <= maxBucket; bucket++ {
+		if !excluded(bucket) {
+			bucketSpan++
+		}
+	}
+	if bucketSpan <= 0 {
+		bucketSpan = 1
+	}
+
+	return float64(totalCount) / float64(bucketSpan)
+}
+
+// DescribeMatch names what lines were matched against: the pattern texts joined with "or", or the
+// regex between slashes. Quoted wraps each plain pattern in single quotes for use inside a sentence.
+func DescribeMatch(patterns []string, regex *regexp.Regexp, quoted bool) string {
+	if regex != nil {
+		return "/" + regex.String() + "/"
+	}
+	names := patterns
+	if quoted {
+		names = make([]string, len(patterns))
+		for i, pattern := range patterns {
+			names[i] = "'" + pattern + "'"
+		}
+	}
+	return strings.Join(names, " or ")
+}
+
+func newFolderResult(folderPath string, opts Options) FolderResult {
+	// A pattern from the folder's config entry wins over --pattern and --regex
+	patterns, regex := opts.Patterns, opts.Regex
+	if folderPattern, ok := opts.FolderPatterns[folderPath]; ok {
+		patterns, regex = []string{folderPattern}, nil
+	}
+
+	return FolderResult{
+		FolderPath:          folderPath,
+		Patterns:            patterns,
+		IgnoreCase:          opts.IgnoreCase,
+		Regex:               regex,
+		DateCountMap:        make(map[string]int),
+		FileCountMap:        make(map[string]int),
+		DateHourlyData:      make(map[string]map[int]int),
+		MinuteBuckets:       opts.MinuteBuckets,
+		ExcludedHours:       opts.ExcludeHours,
+		DateUnknownHour:     make(map[string]int),
+		DateRecipientSketch: make(map[string]*HyperLogLog),
+		Recipients:          make(map[string]bool),
+		DomainCountMap:      make(map[string]int),
+		DateResultCounts:    make(map[string]map[string]int),
+		PatternDateCounts:   make(map[string]map[string]int),
+		FileTimeRanges:      make(map[string]TimeRange),
+		OversizedFiles:      make(map[string]int64),
+		CappedFiles:         make(map[string]bool),
+		AbortedFiles:        make(map[string]bool),
+		ClientCountMap:      make(map[string]int),
+	}
+}
+
+// MergeCounts adds every count in src to dst
+func MergeCounts(dst, src map[string]int) {
+	for key, count := range src {
+		dst[key] += count
+	}
+}
+
+// KeysByCountDesc returns the keys of a count map, highest count first and ties in ascending key order
+func KeysByCountDesc(m map[string]int) []string {
+	keys := SortedKeys(m)
+	sort.SliceStable(keys, func(i, j int) bool {
+		return m[keys[i]] > m[keys[j]]
+	})
+	return keys
+}
+
+// SortedKeys returns the keys of a map in ascending order, so listings don't follow
+// Go's randomized map iteration. YYYY-MM-DD dates sort chronologically this way.
+func SortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
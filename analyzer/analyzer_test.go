@@ -0,0 +1,211 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates each named file with its content in dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestProcessFolder(t *testing.T) {
+	tests := []struct {
+		name        string
+		files       map[string]string
+		opts        Options
+		wantErr     string
+		wantTotal   int
+		wantDates   map[string]int
+		wantFiles   map[string]int
+		wantHours   map[string]map[int]int
+		wantUnknown map[string]int
+		wantSkipped int
+		wantWarning string
+	}{
+		{
+			name: "valid entries",
+			files: map[string]string{
+				"a.txt": "2024-06-15 09:12:01 [INFO] 2FA - Email sent\n" +
+					"2024-06-15 09:40:13 [INFO] Login ok\n" +
+					"2024-06-16 10:00:00 [INFO] 2FA - Email sent\n",
+				"b.txt": "2024-06-16 11:30:00 [INFO] 2FA - Email sent\n",
+			},
+			wantTotal: 3,
+			wantDates: map[string]int{"2024-06-15": 1, "2024-06-16": 2},
+			wantFiles: map[string]int{"a.txt": 2, "b.txt": 1},
+		},
+		{
+			name: "malformed dates",
+			files: map[string]string{
+				"a.txt": "2024-13-45 09:00:00 2FA - Email sent\n" +
+					"yesterday 2FA - Email sent\n" +
+					"2024-06-15 09:00:00 2FA - Email sent\n",
+			},
+			wantTotal:   1,
+			wantDates:   map[string]int{"2024-06-15": 1},
+			wantFiles:   map[string]int{"a.txt": 1},
+			wantSkipped: 2,
+		},
+		{
+			name: "malformed dates over the error rate",
+			files: map[string]string{
+				"a.txt": "2024-13-45 09:00:00 2FA - Email sent\n" +
+					"yesterday 2FA - Email sent\n" +
+					"2024-06-15 09:00:00 2FA - Email sent\n",
+			},
+			opts:        Options{MaxErrorRate: 0.5, ErrorSample: 2},
+			wantDates:   map[string]int{},
+			wantFiles:   map[string]int{"a.txt": 0},
+			wantSkipped: 2,
+			wantWarning: "Aborting file",
+		},
+		{
+			name:    "empty folder",
+			wantErr: "no .txt files found in folder",
+		},
+		{
+			name: "no .txt files",
+			files: map[string]string{
+				"a.log": "2024-06-15 09:00:00 2FA - Email sent\n",
+				"b.csv": "2024-06-15,09:00:00,2FA - Email sent\n",
+			},
+			wantErr: "no .txt files found in folder",
+		},
+		{
+			name: "mixed files",
+			files: map[string]string{
+				"a.txt":  "2024-06-15 09:00:00 2FA - Email sent\n",
+				"b.log":  "2024-06-15 10:00:00 2FA - Email sent\n",
+				"notes":  "2024-06-15 11:00:00 2FA - Email sent\n",
+				"c.txt~": "2024-06-15 12:00:00 2FA - Email sent\n",
+			},
+			wantTotal: 1,
+			wantDates: map[string]int{"2024-06-15": 1},
+			wantFiles: map[string]int{"a.txt": 1},
+		},
+		{
+			name: "hour extraction",
+			files: map[string]string{
+				"a.txt": "2024-06-15 00:05:00 2FA - Email sent\n" +
+					"2024-06-15 09:59:59 2FA - Email sent\n" +
+					"2024-06-15 09:00:00 2FA - Email sent\n" +
+					"2024-06-15 23:00:00 2FA - Email sent\n" +
+					"2024-06-15 24:00:00 2FA - Email sent\n" +
+					"2024-06-15 noon 2FA - Email sent\n",
+			},
+			wantTotal:   6,
+			wantDates:   map[string]int{"2024-06-15": 6},
+			wantFiles:   map[string]int{"a.txt": 6},
+			wantHours:   map[string]map[int]int{"2024-06-15": {0: 1, 9: 2, 23: 1}},
+			wantUnknown: map[string]int{"2024-06-15": 2},
+		},
+		{
+			name: "overlong line",
+			files: map[string]string{
+				"a.txt": "2024-06-15 09:00:00 2FA - Email sent " + strings.Repeat("x", 100) + "\n" +
+					"2024-06-15 10:00:00 2FA - Email sent\n",
+			},
+			opts:        Options{MaxLineBytes: 64},
+			wantTotal:   1,
+			wantDates:   map[string]int{"2024-06-15": 1},
+			wantFiles:   map[string]int{"a.txt": 1},
+			wantWarning: "Skipping line 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+
+			var warnings bytes.Buffer
+			opts := tt.opts
+			opts.Logger = NewWarningLogger(&warnings, false)
+			result := ProcessFolder(context.Background(), dir, opts)
+
+			if tt.wantErr != "" {
+				if result.Error == nil || !strings.Contains(result.Error.Error(), tt.wantErr) {
+					t.Fatalf("Error = %v, want one containing %q", result.Error, tt.wantErr)
+				}
+				return
+			}
+			if result.Error != nil {
+				t.Fatalf("unexpected error: %v", result.Error)
+			}
+			if result.TotalCount != tt.wantTotal {
+				t.Errorf("TotalCount = %d, want %d", result.TotalCount, tt.wantTotal)
+			}
+			if !reflect.DeepEqual(result.DateCountMap, tt.wantDates) {
+				t.Errorf("DateCountMap = %v, want %v", result.DateCountMap, tt.wantDates)
+			}
+			if !reflect.DeepEqual(result.FileCountMap, tt.wantFiles) {
+				t.Errorf("FileCountMap = %v, want %v", result.FileCountMap, tt.wantFiles)
+			}
+			if tt.wantHours != nil && !reflect.DeepEqual(result.DateHourlyData, tt.wantHours) {
+				t.Errorf("DateHourlyData = %v, want %v", result.DateHourlyData, tt.wantHours)
+			}
+			if tt.wantUnknown != nil && !reflect.DeepEqual(result.DateUnknownHour, tt.wantUnknown) {
+				t.Errorf("DateUnknownHour = %v, want %v", result.DateUnknownHour, tt.wantUnknown)
+			}
+			if result.SkippedCount != tt.wantSkipped {
+				t.Errorf("SkippedCount = %d, want %d", result.SkippedCount, tt.wantSkipped)
+			}
+
+			if tt.wantWarning == "" && warnings.Len() > 0 {
+				t.Errorf("unexpected warnings:\n%s", warnings.String())
+			}
+			if tt.wantWarning != "" && !strings.Contains(warnings.String(), tt.wantWarning) {
+				t.Errorf("warnings = %q, want one containing %q", warnings.String(), tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestMatchesLineIgnoreCase(t *testing.T) {
+	tests := []struct {
+		name       string
+		patterns   []string
+		regex      string
+		ignoreCase bool
+		line       string
+		want       bool
+	}{
+		{name: "substring exact case", patterns: []string{"2FA - Email"}, line: "09:00 2FA - Email sent", want: true},
+		{name: "substring other case", patterns: []string{"2FA - Email"}, line: "09:00 2fa - EMAIL sent", want: false},
+		{name: "substring ignore case, lower line", patterns: []string{"2FA - Email"}, ignoreCase: true, line: "09:00 2fa - email sent", want: true},
+		{name: "substring ignore case, upper line", patterns: []string{"2fa - email"}, ignoreCase: true, line: "09:00 2FA - EMAIL SENT", want: true},
+		{name: "substring ignore case, mixed both", patterns: []string{"2Fa - eMaIl"}, ignoreCase: true, line: "09:00 2fA - EmAiL sent", want: true},
+		{name: "substring ignore case, second pattern", patterns: []string{"SMS", "Push Sent"}, ignoreCase: true, line: "09:00 push SENT to device", want: true},
+		{name: "substring ignore case, no match", patterns: []string{"2FA - Email"}, ignoreCase: true, line: "09:00 2FA - SMS sent", want: false},
+		{name: "regex exact case", regex: `2FA - (Email|SMS)`, line: "09:00 2FA - SMS sent", want: true},
+		{name: "regex other case", regex: `2FA - (Email|SMS)`, line: "09:00 2fa - sms sent", want: false},
+		{name: "regex ignore case, lower line", regex: `(?i)2FA - (Email|SMS)`, ignoreCase: true, line: "09:00 2fa - sms sent", want: true},
+		{name: "regex ignore case, mixed both", regex: `(?i)2Fa - (eMail|sMs)`, ignoreCase: true, line: "09:00 2FA - EmAiL sent", want: true},
+		{name: "regex ignore case, no match", regex: `(?i)2FA - (Email|SMS)`, ignoreCase: true, line: "09:00 2FA - Push sent", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := FolderResult{Patterns: tt.patterns, IgnoreCase: tt.ignoreCase}
+			if tt.regex != "" {
+				result.Regex = regexp.MustCompile(tt.regex)
+			}
+			if got := result.MatchesLine(tt.line); got != tt.want {
+				t.Errorf("MatchesLine(%q) = %v, want %v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
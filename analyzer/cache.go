@@ -0,0 +1,233 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// ScanFingerprint describes every option that changes what a file contributes to its folder's
+// result, so cached entries made under different options are not reused
+func ScanFingerprint(opts Options, scheduleSpec string) string {
+	location := ""
+	if opts.Location != nil {
+		location = opts.Location.String()
+	}
+	regexText := func(regex *regexp.Regexp) string {
+		if regex == nil {
+			return ""
+		}
+		return regex.String()
+	}
+
+	fingerprint, _ := json.Marshal([]any{
+		regexText(opts.Regex), opts.IgnoreCase, opts.LinePrefix, opts.TimestampLayout, location,
+		opts.Since, opts.Until, opts.Strict, opts.MinuteBuckets, opts.ByMinuteOfHour, opts.ApproxDistinct,
+		regexText(opts.ResultRegex), regexText(opts.ClientRegex), scheduleSpec, opts.ScheduleWindow,
+		opts.MaxMatches, opts.MaxErrorRate, opts.ErrorSample, opts.MaxLineBytes, opts.CheckOverlap,
+		opts.ExcludeHours,
+	})
+	return string(fingerprint)
+}
+
+// cacheVersion is bumped whenever cacheEntry changes shape, so old cache files are ignored
+const cacheVersion = 2
+
+// Cache keeps each file's scan results between runs (--cache), keyed by absolute path. An
+// entry is reused while the file's size and modification time and the scan options are unchanged.
+type Cache struct {
+	mu          sync.Mutex
+	path        string
+	fingerprint string // the scan options of this run; entries made with others are rescanned
+	files       map[string]cacheEntry
+	dirty       bool
+}
+
+// cacheFile is the on-disk form of the cache
+type cacheFile struct {
+	Version int                   `json:"version"`
+	Files   map[string]cacheEntry `json:"files"`
+}
+
+// cacheEntry is one file's contribution to its folder's result
+type cacheEntry struct {
+	ModTime time.Time `json:"mod_time"`
+	Size    int64     `json:"size"`
+	Options string    `json:"options"`
+
+	Count           int                       `json:"count"`
+	Dates           map[string]int            `json:"dates,omitempty"`
+	Hourly          map[string]map[int]int    `json:"hourly,omitempty"`
+	UnknownHour     map[string]int            `json:"unknown_hour,omitempty"`
+	Recipients      []string                  `json:"recipients,omitempty"`
+	Domains         map[string]int            `json:"domains,omitempty"`
+	Results         map[string]map[string]int `json:"results,omitempty"`
+	Clients         map[string]int            `json:"clients,omitempty"`
+	PatternDates    map[string]map[string]int `json:"pattern_dates,omitempty"`
+	MinuteOfHour    [60]int                   `json:"minute_of_hour"`
+	Sketches        map[string][]byte         `json:"sketches,omitempty"`
+	Skipped         int                       `json:"skipped,omitempty"`
+	SkippedSamples  []string                  `json:"skipped_samples,omitempty"`
+	Lines           int64                     `json:"lines"`
+	Bytes           int64                     `json:"bytes"`
+	ScheduleAligned int                       `json:"schedule_aligned,omitempty"`
+	ScheduleOff     int                       `json:"schedule_off,omitempty"`
+	Capped          bool                      `json:"capped,omitempty"`
+	Aborted         bool                      `json:"aborted,omitempty"`
+	DateRange       dateRange                 `json:"date_range"`
+	TimeRange       TimeRange                 `json:"time_range"`
+}
+
+// LoadCache reads the cache file at path. A missing file starts an empty cache; an unreadable
+// or outdated one is replaced, since the cache only saves time and never changes results. The
+// returned cache is always usable; the error only explains why an existing file was not reused.
+func LoadCache(path, fingerprint string) (*Cache, error) {
+	cache := &Cache{path: path, fingerprint: fingerprint, files: make(map[string]cacheEntry)}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return cache, nil
+		}
+		return cache, fmt.Errorf("cannot read cache %s, starting a new one: %w", path, err)
+	}
+
+	var stored cacheFile
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return cache, fmt.Errorf("cannot parse cache %s, starting a new one: %w", path, err)
+	}
+	if stored.Version == cacheVersion && stored.Files != nil {
+		cache.files = stored.Files
+	}
+	return cache, nil
+}
+
+// lookup returns the cached entry for a file if it is still valid for the file and options
+func (c *Cache) lookup(filePath string, info fs.FileInfo, options string) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.files[filePath]
+	if !ok || entry.Size != info.Size() || !entry.ModTime.Equal(info.ModTime()) || entry.Options != options {
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+// store records a freshly scanned file
+func (c *Cache) store(filePath string, entry cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files[filePath] = entry
+	c.dirty = true
+}
+
+// Save writes the cache back if anything changed, dropping entries for files that no longer exist.
+// It writes a temporary file first so an interrupted save can't leave a truncated cache.
+func (c *Cache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for filePath := range c.files {
+		if _, err := os.Stat(filePath); errors.Is(err, fs.ErrNotExist) {
+			delete(c.files, filePath)
+			c.dirty = true
+		}
+	}
+	if !c.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(cacheFile{Version: cacheVersion, Files: c.files})
+	if err != nil {
+		return err
+	}
+	tempPath := c.path + ".tmp"
+	if err := os.WriteFile(tempPath, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, c.path)
+}
+
+// newCacheEntry captures what scanning one file added to a partial scan
+func newCacheEntry(partial *folderScan, fileName string, info fs.FileInfo, options string) cacheEntry {
+	result := partial.result
+	entry := cacheEntry{
+		ModTime:         info.ModTime(),
+		Size:            info.Size(),
+		Options:         options,
+		Count:           result.FileCountMap[fileName],
+		Dates:           result.DateCountMap,
+		Hourly:          result.DateHourlyData,
+		UnknownHour:     result.DateUnknownHour,
+		Recipients:      SortedKeys(result.Recipients),
+		Domains:         result.DomainCountMap,
+		Results:         result.DateResultCounts,
+		Clients:         result.ClientCountMap,
+		PatternDates:    result.PatternDateCounts,
+		MinuteOfHour:    result.MinuteOfHourCounts,
+		Sketches:        make(map[string][]byte),
+		Skipped:         result.SkippedCount,
+		SkippedSamples:  result.SkippedSamples,
+		Lines:           result.LinesScanned,
+		Bytes:           result.BytesScanned,
+		ScheduleAligned: result.ScheduleAligned,
+		ScheduleOff:     result.ScheduleOff,
+		Capped:          result.CappedFiles[fileName],
+		Aborted:         result.AbortedFiles[fileName],
+		DateRange:       partial.fileDateRanges[fileName],
+		TimeRange:       result.FileTimeRanges[fileName],
+	}
+	for date, sketch := range result.DateRecipientSketch {
+		entry.Sketches[date] = sketch.registers
+	}
+	return entry
+}
+
+// restore fills an empty partial scan as if the file had just been scanned
+func (e cacheEntry) restore(partial *folderScan, fileName string) {
+	result := partial.result
+	result.TotalCount = e.Count
+	result.FileCountMap[fileName] = e.Count
+	MergeCounts(result.DateCountMap, e.Dates)
+	MergeCounts(result.DateUnknownHour, e.UnknownHour)
+	MergeCounts(result.DomainCountMap, e.Domains)
+	MergeCounts(result.ClientCountMap, e.Clients)
+	for date, hourlyData := range e.Hourly {
+		result.DateHourlyData[date] = hourlyData
+	}
+	for date, buckets := range e.Results {
+		result.DateResultCounts[date] = buckets
+	}
+	for pattern, dates := range e.PatternDates {
+		result.PatternDateCounts[pattern] = dates
+	}
+	for _, email := range e.Recipients {
+		result.Recipients[email] = true
+	}
+	for date, registers := range e.Sketches {
+		result.DateRecipientSketch[date] = &HyperLogLog{registers: registers}
+	}
+	result.MinuteOfHourCounts = e.MinuteOfHour
+	result.SkippedCount = e.Skipped
+	result.SkippedSamples = e.SkippedSamples
+	result.LinesScanned = e.Lines
+	result.BytesScanned = e.Bytes
+	result.ScheduleAligned = e.ScheduleAligned
+	result.ScheduleOff = e.ScheduleOff
+	if e.Capped {
+		result.CappedFiles[fileName] = true
+	}
+	if e.Aborted {
+		result.AbortedFiles[fileName] = true
+	}
+	if !e.TimeRange.First.IsZero() {
+		result.FileTimeRanges[fileName] = e.TimeRange
+	}
+	if e.DateRange != (dateRange{}) {
+		partial.fileDateRanges[fileName] = e.DateRange
+	}
+}
@@ -0,0 +1,323 @@
+package analyzer
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DiscoverFiles returns the log files ProcessFolder will scan in a folder, including
+// those in subfolders with --recursive. Zip archives in the folder are listed too; their
+// entries are scanned like files. A path naming a single file is scanned as given,
+// whatever its extension.
+func DiscoverFiles(folderPath string, opts Options) ([]string, error) {
+	opts = opts.withDefaults()
+	if info, err := os.Stat(folderPath); err == nil && info.Mode().IsRegular() {
+		return []string{folderPath}, nil
+	}
+
+	var files []string
+	if opts.Recursive {
+		var err error
+		files, err = walkLogFiles(folderPath, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error reading folder: %w", err)
+		}
+	} else {
+		// Glob once per extension; on case-insensitive file systems "*.log" and "*.LOG"
+		// return the same files, so the merged list is de-duplicated. Rotated logs are often
+		// gzipped, so "app.txt.gz" counts as a .txt file.
+		patterns := []string{"*.zip"}
+		for _, ext := range opts.Extensions {
+			patterns = append(patterns, "*."+ext, "*."+ext+".gz")
+		}
+		seen := make(map[string]bool)
+		for _, pattern := range patterns {
+			matches, err := filepath.Glob(filepath.Join(folderPath, pattern))
+			if err != nil {
+				return nil, fmt.Errorf("error reading folder: %w", err)
+			}
+			for _, filePath := range matches {
+				if !seen[filePath] {
+					seen[filePath] = true
+					files = append(files, filePath)
+				}
+			}
+		}
+		sort.Strings(files)
+	}
+
+	if len(opts.Include) > 0 || len(opts.Exclude) > 0 {
+		matched := files[:0]
+		for _, filePath := range files {
+			if passesFileFilters(filepath.Base(filePath), opts.Include, opts.Exclude) {
+				matched = append(matched, filePath)
+			}
+		}
+		if len(files) > 0 && len(matched) == 0 {
+			return nil, fmt.Errorf("no %s files found in folder matching --include/--exclude", DescribeExtensions(opts.Extensions))
+		}
+		files = matched
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no %s files found in folder", DescribeExtensions(opts.Extensions))
+	}
+
+	return files, nil
+}
+
+// walkLogFiles lists the log files in a folder and all its subfolders, in lexical order. The folder
+// itself may be a symlink. Symlinked subfolders are skipped with a warning unless FollowSymlinks is
+// set; then each directory is entered once by its resolved path, so a link back to a parent can't
+// loop. Symlinked files are listed like any other file.
+func walkLogFiles(folderPath string, opts Options) ([]string, error) {
+	var files []string
+	visited := make(map[string]bool)
+
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		if resolved, err := filepath.EvalSymlinks(dir); err == nil {
+			if visited[resolved] {
+				opts.Logger.Warnf(folderPath, dir, "Skipping %s: already scanned as %s (symlink loop?)", dir, resolved)
+				return nil
+			}
+			visited[resolved] = true
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			entryPath := filepath.Join(dir, entry.Name())
+			isDir := entry.IsDir()
+			if entry.Type()&fs.ModeSymlink != 0 {
+				if target, err := os.Stat(entryPath); err == nil && target.IsDir() {
+					if !opts.FollowSymlinks {
+						opts.Logger.Warnf(folderPath, entryPath, "Skipping symlinked folder %s (use --follow-symlinks to scan it)", entryPath)
+						continue
+					}
+					isDir = true
+				}
+			}
+
+			if isDir {
+				if err := walk(entryPath); err != nil {
+					return err
+				}
+			} else if hasLogExtension(entryPath, opts.Extensions) || IsZipArchive(entryPath) {
+				files = append(files, entryPath)
+			}
+		}
+		return nil
+	}
+
+	if err := walk(folderPath); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// SkipOversizedFiles separates files larger than maxSize bytes from the rest, returning the kept
+// files in their original order and the size of each oversized one. A maxSize of 0 keeps every
+// file; so do files that can't be stat'ed, whose error is reported when they are opened.
+func SkipOversizedFiles(files []string, maxSize int64) (kept []string, oversized map[string]int64) {
+	oversized = make(map[string]int64)
+	if maxSize <= 0 {
+		return files, oversized
+	}
+	for _, filePath := range files {
+		if info, err := os.Stat(filePath); err == nil && info.Size() > maxSize {
+			oversized[filePath] = info.Size()
+			continue
+		}
+		kept = append(kept, filePath)
+	}
+	return kept, oversized
+}
+
+// passesFileFilters reports whether a base file name should be scanned: it must match one of the
+// include globs (if any) and none of the exclude globs. Exclude wins when both match.
+func passesFileFilters(name string, include, exclude []string) bool {
+	for _, pattern := range exclude {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return false
+		}
+	}
+	if len(include) == 0 {
+		return true
+	}
+	for _, pattern := range include {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeExtensions trims dots and spaces from extension arguments and drops empty and repeated ones
+func NormalizeExtensions(extensions []string) []string {
+	var normalized []string
+	seen := make(map[string]bool)
+	for _, ext := range extensions {
+		ext = strings.TrimPrefix(strings.TrimSpace(ext), ".")
+		if ext != "" && !seen[ext] {
+			seen[ext] = true
+			normalized = append(normalized, ext)
+		}
+	}
+	return normalized
+}
+
+// hasLogExtension reports whether a file name ends in one of the extensions, optionally followed by .gz
+func hasLogExtension(name string, extensions []string) bool {
+	name = strings.TrimSuffix(name, ".gz")
+	for _, ext := range extensions {
+		if filepath.Ext(name) == "."+ext {
+			return true
+		}
+	}
+	return false
+}
+
+// DescribeExtensions lists extensions for messages, e.g. ".txt or .log"
+func DescribeExtensions(extensions []string) string {
+	dotted := make([]string, len(extensions))
+	for i, ext := range extensions {
+		dotted[i] = "." + ext
+	}
+	if len(dotted) == 1 {
+		return dotted[0]
+	}
+	return strings.Join(dotted[:len(dotted)-1], ", ") + " or " + dotted[len(dotted)-1]
+}
+
+// ListZipEntries returns "archive.zip/entry" paths for the log entries of a zip archive that
+// ProcessFolder would scan with opts, or an error when it has none
+func ListZipEntries(archivePath string, opts Options) ([]string, error) {
+	opts = opts.withDefaults()
+	archive, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening zip archive: %w", err)
+	}
+	defer archive.Close()
+
+	if abs, err := filepath.Abs(archivePath); err == nil {
+		archivePath = abs
+	}
+
+	entries, err := zipLogEntries(&archive.Reader, opts)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, len(entries))
+	for i, entry := range entries {
+		paths[i] = archivePath + "/" + entry.Name
+	}
+	return paths, nil
+}
+
+// processZipArchive scans the log entries of a zip archive as if the archive were a folder
+func processZipArchive(ctx context.Context, archivePath string, opts Options) FolderResult {
+	result := newFolderResult(archivePath, opts)
+
+	archive, err := zip.OpenReader(archivePath)
+	if err != nil {
+		result.Error = fmt.Errorf("error opening zip archive: %w", err)
+		return result
+	}
+	defer archive.Close()
+
+	entries, err := zipLogEntries(&archive.Reader, opts)
+	if err != nil {
+		result.Error = err
+		return result
+	}
+
+	scan := newFolderScan(ctx, &result, opts)
+	scan.scanZipEntries(entries, archivePath, "")
+	scan.finish()
+
+	return result
+}
+
+// scanZipEntries scans the log entries of an archive, listing each under namePrefix plus its
+// name inside the archive. An entry that can't be read is skipped with a warning.
+func (s *folderScan) scanZipEntries(entries []*zip.File, archivePath, namePrefix string) {
+	folderPath, opts := s.result.FolderPath, s.opts
+	for _, entry := range entries {
+		if s.ctx.Err() != nil {
+			s.result.Error = ErrCancelled
+			return
+		}
+
+		entryPath := archivePath + "/" + entry.Name
+
+		reader, err := entry.Open()
+		if err != nil {
+			opts.Logger.Warnf(folderPath, entryPath, "Error opening archive entry %s: %v", entryPath, err)
+			continue
+		}
+
+		var input io.Reader = reader
+		if strings.EqualFold(path.Ext(entry.Name), ".gz") {
+			gzipReader, err := gzip.NewReader(reader)
+			if err != nil {
+				opts.Logger.Warnf(folderPath, entryPath, "Error decompressing archive entry %s: %v", entryPath, err)
+				reader.Close()
+				continue
+			}
+			input = gzipReader
+		}
+
+		s.scanFile(input, entryPath, namePrefix+entry.Name)
+		reader.Close()
+		if s.result.Error != nil {
+			return
+		}
+	}
+}
+
+// IsZipArchive reports whether an input path names a zip archive rather than a folder
+func IsZipArchive(inputPath string) bool {
+	return strings.EqualFold(filepath.Ext(inputPath), ".zip")
+}
+
+// zipLogEntries returns the entries of an archive a folder scan would pick up, sorted by name:
+// those with one of the log extensions (optionally gzipped) whose base name passes --include and
+// --exclude. Like DiscoverFiles, it returns an error when none are left.
+func zipLogEntries(archive *zip.Reader, opts Options) ([]*zip.File, error) {
+	var entries []*zip.File
+	logFiles := 0
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() || !hasLogExtension(entry.Name, opts.Extensions) {
+			continue
+		}
+		logFiles++
+		if passesFileFilters(path.Base(entry.Name), opts.Include, opts.Exclude) {
+			entries = append(entries, entry)
+		}
+	}
+
+	switch {
+	case len(entries) > 0:
+	case logFiles > 0:
+		return nil, fmt.Errorf("no %s files found in zip archive matching --include/--exclude", DescribeExtensions(opts.Extensions))
+	default:
+		return nil, fmt.Errorf("no %s files found in zip archive", DescribeExtensions(opts.Extensions))
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	return entries, nil
+}
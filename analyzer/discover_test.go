@@ -0,0 +1,151 @@
+package analyzer
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPassesFileFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		include []string
+		exclude []string
+		want    bool
+	}{
+		{name: "no filters", file: "app.txt", want: true},
+		{name: "include matches", file: "app-2024.txt", include: []string{"app-*"}, want: true},
+		{name: "include matches a later glob", file: "mail.txt", include: []string{"app-*", "mail*"}, want: true},
+		{name: "include doesn't match", file: "debug.txt", include: []string{"app-*"}, want: false},
+		{name: "exclude matches", file: "debug.txt", exclude: []string{"debug*"}, want: false},
+		{name: "exclude doesn't match", file: "app.txt", exclude: []string{"debug*"}, want: true},
+		{name: "exclude wins over include", file: "app-debug.txt", include: []string{"app-*"}, exclude: []string{"*debug*"}, want: false},
+		{name: "include only, exclude elsewhere", file: "app-2024.txt", include: []string{"app-*"}, exclude: []string{"*debug*"}, want: true},
+		{name: "globs match the whole name", file: "app.txt.gz", include: []string{"*.txt"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := passesFileFilters(tt.file, tt.include, tt.exclude); got != tt.want {
+				t.Errorf("passesFileFilters(%q, %q, %q) = %v, want %v", tt.file, tt.include, tt.exclude, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscoverFilesFilters(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"app-1.txt":     "",
+		"app-2.txt":     "",
+		"app-debug.txt": "",
+		"mail.txt":      "",
+		"notes.log":     "",
+	})
+
+	tests := []struct {
+		name    string
+		opts    Options
+		want    []string
+		wantErr string
+	}{
+		{name: "no filters", want: []string{"app-1.txt", "app-2.txt", "app-debug.txt", "mail.txt"}},
+		{name: "include", opts: Options{Include: []string{"app-*"}}, want: []string{"app-1.txt", "app-2.txt", "app-debug.txt"}},
+		{name: "exclude", opts: Options{Exclude: []string{"app-*"}}, want: []string{"mail.txt"}},
+		{name: "exclude wins", opts: Options{Include: []string{"app-*"}, Exclude: []string{"*debug*"}}, want: []string{"app-1.txt", "app-2.txt"}},
+		{
+			name:    "nothing left after filtering",
+			opts:    Options{Include: []string{"app-*"}, Exclude: []string{"app-*"}},
+			wantErr: "no .txt files found in folder matching --include/--exclude",
+		},
+		{
+			name:    "include matching only other extensions",
+			opts:    Options{Include: []string{"*.log"}},
+			wantErr: "no .txt files found in folder matching --include/--exclude",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := DiscoverFiles(dir, tt.opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("DiscoverFiles error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var names []string
+			for _, filePath := range files {
+				names = append(names, filepath.Base(filePath))
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("DiscoverFiles = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestListZipEntries(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "logs.zip")
+	file, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := zip.NewWriter(file)
+	for _, name := range []string{"app.log", "debug.log", "sub/mail.txt", "old.txt.gz", "notes.md", "other.gz"} {
+		if _, err := writer.Create(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	tests := []struct {
+		name    string
+		opts    Options
+		want    []string
+		wantErr string
+	}{
+		{name: "default extension", want: []string{"old.txt.gz", "sub/mail.txt"}},
+		{name: "--ext log", opts: Options{Extensions: []string{"log"}}, want: []string{"app.log", "debug.log"}},
+		{name: "exclude", opts: Options{Extensions: []string{"log"}, Exclude: []string{"debug*"}}, want: []string{"app.log"}},
+		{name: "include matches base names", opts: Options{Include: []string{"mail*"}}, want: []string{"sub/mail.txt"}},
+		{name: "no matching extension", opts: Options{Extensions: []string{"csv"}}, wantErr: "no .csv files found in zip archive"},
+		{
+			name:    "nothing left after filtering",
+			opts:    Options{Extensions: []string{"log"}, Include: []string{"mail*"}},
+			wantErr: "no .log files found in zip archive matching --include/--exclude",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			paths, err := ListZipEntries(archivePath, tt.opts)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("ListZipEntries error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var names []string
+			for _, entryPath := range paths {
+				names = append(names, strings.TrimPrefix(entryPath, archivePath+"/"))
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("ListZipEntries = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
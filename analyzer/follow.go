@@ -0,0 +1,235 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// FollowInterval is how often --follow checks the files for appended lines
+const FollowInterval = 5 * time.Second
+
+// followedFolder is a folder tailed by --follow: its running result and how far each file has been read
+type followedFolder struct {
+	result    *FolderResult
+	scan      *folderScan
+	offsets   map[string]int64        // end of the complete lines already scanned, by file path
+	encodings map[string]textEncoding // encoding read from each file's BOM, for decoding later appends
+	static    bool                    // a zip archive, read once since it can't grow in place
+	noFiles   error                   // the discovery error the folder failed with while it had no files yet
+	reported  int                     // TotalCount at the last status update, -1 before the first
+}
+
+// Follow scans every folder like a normal run, then keeps polling the files for appended
+// lines until ctx is cancelled by Ctrl+C, writing the running counts to opts.Status whenever
+// they change. Files that appear later are picked up as well. The final results are returned for the
+// usual report.
+func Follow(ctx context.Context, folderPaths []string, opts Options) []FolderResult {
+	opts = opts.withDefaults()
+	folders := make([]*followedFolder, len(folderPaths))
+	for i, folderPath := range folderPaths {
+		result := newFolderResult(folderPath, opts)
+		// Each file's new lines are scanned to the end; Ctrl+C only stops the polling between files
+		folders[i] = &followedFolder{
+			result:    &result,
+			scan:      newFolderScan(context.Background(), &result, opts),
+			offsets:   make(map[string]int64),
+			encodings: make(map[string]textEncoding),
+			reported:  -1,
+		}
+	}
+
+	ticker := time.NewTicker(FollowInterval)
+	defer ticker.Stop()
+	for {
+		for _, folder := range folders {
+			folder.poll(ctx)
+		}
+		printFollowStatus(opts.Status, folders)
+
+		select {
+		case <-ctx.Done():
+			results := make([]FolderResult, len(folders))
+			for i, folder := range folders {
+				if !folder.static {
+					folder.scan.finish()
+				}
+				results[i] = *folder.result
+			}
+			return results
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll scans whatever was appended to the folder's files since the last poll
+func (f *followedFolder) poll(ctx context.Context) {
+	if f.static {
+		return
+	}
+	if IsZipArchive(f.result.FolderPath) {
+		*f.result = processZipArchive(context.Background(), f.result.FolderPath, f.scan.opts)
+		f.static = true
+		return
+	}
+
+	files, err := DiscoverFiles(f.result.FolderPath, f.scan.opts)
+	if err != nil {
+		// A folder stays failed until it has files; one that had them keeps its counts
+		if len(f.offsets) == 0 {
+			f.result.Error, f.noFiles = err, err
+		}
+		return
+	}
+	// Errors from scanning (--strict, --max-error-rate, Ctrl+C) are kept for the report
+	if f.noFiles != nil && f.result.Error == f.noFiles {
+		f.result.Error = nil
+	}
+	f.noFiles = nil
+
+	for _, filePath := range files {
+		if ctx.Err() != nil {
+			return
+		}
+		f.readAppended(filePath)
+	}
+}
+
+// readAppended scans the complete lines added to a file since it was last read. A trailing line
+// without its newline yet is left for the next poll. Gzip files and zip archives can't be tailed
+// and are read once.
+func (f *followedFolder) readAppended(filePath string) {
+	offset, seen := f.offsets[filePath]
+	if strings.HasSuffix(filePath, ".gz") || IsZipArchive(filePath) {
+		if !seen {
+			f.scan.scanFolderFile(filePath)
+			f.offsets[filePath] = 0
+		}
+		return
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		f.scan.opts.Logger.Warnf(f.result.FolderPath, filePath, "Error opening file %s: %v", filePath, err)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		f.scan.opts.Logger.Warnf(f.result.FolderPath, filePath, "Error reading file %s: %v", filePath, err)
+		return
+	}
+
+	// A file that shrank was truncated or replaced by rotation, so it is read again from the start.
+	// Entries counted from its old content are kept.
+	if info.Size() < offset {
+		offset = 0
+	}
+
+	// Only a read from the start sees the BOM, so the encoding is remembered for later appends
+	encoding, known := f.encodings[filePath]
+	if offset == 0 || !known {
+		start := make([]byte, 2)
+		n, _ := file.ReadAt(start, 0)
+		encoding = detectEncoding(start[:n])
+		f.encodings[filePath] = encoding
+	}
+
+	end, err := completeLinesEnd(file, offset, info.Size(), encoding)
+	if err != nil {
+		f.scan.opts.Logger.Warnf(f.result.FolderPath, filePath, "Error reading file %s: %v", filePath, err)
+		return
+	}
+	if end > offset {
+		var appended io.Reader = io.NewSectionReader(file, offset, end-offset)
+		if offset > 0 {
+			appended = encoding.decode(appended)
+		}
+		f.scan.scanFile(appended, filePath, f.scan.fileName(filePath))
+	}
+	f.offsets[filePath] = end
+}
+
+// completeLinesEnd returns the offset just past the last newline in [start, end), or start if that
+// range holds no newline. It reads backwards from end, so a large first read costs one block.
+func completeLinesEnd(file io.ReaderAt, start, end int64, encoding textEncoding) (int64, error) {
+	buffer := make([]byte, 64*1024)
+	for blockEnd := end; blockEnd > start; {
+		blockStart := max(start, blockEnd-int64(len(buffer)))
+		block := buffer[:blockEnd-blockStart]
+		if _, err := file.ReadAt(block, blockStart); err != nil && err != io.EOF {
+			return start, err
+		}
+		for newline := bytes.LastIndexByte(block, '\n'); newline >= 0; newline = bytes.LastIndexByte(block[:newline], '\n') {
+			lineEnd, ok, err := newlineEnd(file, blockStart+int64(newline), start, end, encoding)
+			if err != nil {
+				return start, err
+			}
+			if ok {
+				return lineEnd, nil
+			}
+		}
+		blockEnd = blockStart
+	}
+	return start, nil
+}
+
+// newlineEnd checks that the 0x0A byte at position is a newline in the file's encoding and returns
+// the offset just past it. In UTF-16 a newline is a whole code unit at an even offset (0A 00 or
+// 00 0A), so the 0x0A half of another character is passed over, as is a newline not fully written.
+func newlineEnd(file io.ReaderAt, position, start, end int64, encoding textEncoding) (int64, bool, error) {
+	other := make([]byte, 1)
+	switch encoding {
+	case encodingUTF16LE:
+		if position%2 != 0 || position+2 > end {
+			return 0, false, nil
+		}
+		if _, err := file.ReadAt(other, position+1); err != nil {
+			return 0, false, err
+		}
+		return position + 2, other[0] == 0, nil
+	case encodingUTF16BE:
+		if position%2 != 1 || position-1 < start {
+			return 0, false, nil
+		}
+		if _, err := file.ReadAt(other, position-1); err != nil {
+			return 0, false, err
+		}
+		return position + 1, other[0] == 0, nil
+	default:
+		return position + 1, true, nil
+	}
+}
+
+// printFollowStatus writes the running totals to out (stderr for the command, keeping stdout for
+// the final report) when any folder's count changed since the last update
+func printFollowStatus(out io.Writer, folders []*followedFolder) {
+	if out == nil {
+		return
+	}
+	changed := false
+	total := 0
+	for _, folder := range folders {
+		total += folder.result.TotalCount
+		changed = changed || folder.result.TotalCount != folder.reported
+	}
+	if !changed {
+		return
+	}
+
+	fmt.Fprintf(out, "[%s] %d entries\n", time.Now().Format("2006-01-02 15:04:05"), total)
+	for _, folder := range folders {
+		if folder.result.Error != nil {
+			fmt.Fprintf(out, "  %s: error: %v\n", folder.result.FolderPath, folder.result.Error)
+			continue
+		}
+		added := folder.result.TotalCount - max(folder.reported, 0)
+		fmt.Fprintf(out, "  %s: %d (+%d)\n", folder.result.FolderPath, folder.result.TotalCount, added)
+		folder.reported = folder.result.TotalCount
+	}
+}
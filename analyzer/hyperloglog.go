@@ -0,0 +1,78 @@
+package analyzer
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// hyperLogLogPrecision is the number of index bits; 2^14 registers keep each sketch at 16 KiB
+const hyperLogLogPrecision = 14
+
+// HyperLogLog is a fixed-size sketch estimating the number of distinct values added to it
+type HyperLogLog struct {
+	registers []uint8
+}
+
+func NewHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{registers: make([]uint8, 1<<hyperLogLogPrecision)}
+}
+
+// Add records a value in the sketch
+func (h *HyperLogLog) Add(value string) {
+	hasher := fnv.New64a()
+	hasher.Write([]byte(value))
+	hash := mixHash(hasher.Sum64())
+
+	index := hash >> (64 - hyperLogLogPrecision)
+	rank := uint8(bits.LeadingZeros64(hash<<hyperLogLogPrecision|1<<(hyperLogLogPrecision-1))) + 1
+	if rank > h.registers[index] {
+		h.registers[index] = rank
+	}
+}
+
+// Merge folds another sketch into this one, as if its values had been added here
+func (h *HyperLogLog) Merge(other *HyperLogLog) {
+	for i, rank := range other.registers {
+		if rank > h.registers[i] {
+			h.registers[i] = rank
+		}
+	}
+}
+
+// Estimate returns the approximate number of distinct values added
+func (h *HyperLogLog) Estimate() uint64 {
+	m := float64(len(h.registers))
+	sum := 0.0
+	zeros := 0
+	for _, rank := range h.registers {
+		sum += math.Ldexp(1, -int(rank))
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+
+	// Linear counting is more accurate while many registers are still empty
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(estimate + 0.5)
+}
+
+// HyperLogLogErrorBound is the relative standard error of an estimate
+func HyperLogLogErrorBound() float64 {
+	return 1.04 / math.Sqrt(float64(int(1)<<hyperLogLogPrecision))
+}
+
+// mixHash spreads FNV output across all 64 bits (splitmix64 finalizer)
+func mixHash(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
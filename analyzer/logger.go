@@ -0,0 +1,59 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// WarningLogger writes scan warnings and folder errors, as plain text or one JSON object per line
+type WarningLogger struct {
+	mu   sync.Mutex
+	out  io.Writer
+	json bool
+}
+
+// NewWarningLogger returns a logger writing to out, as one JSON Diagnostic per line when jsonLines is set
+func NewWarningLogger(out io.Writer, jsonLines bool) *WarningLogger {
+	return &WarningLogger{out: out, json: jsonLines}
+}
+
+// Diagnostic is the JSON form of a single warning or error
+type Diagnostic struct {
+	Level   string `json:"level"`
+	Folder  string `json:"folder,omitempty"`
+	File    string `json:"file,omitempty"`
+	Message string `json:"message"`
+}
+
+// Warnf reports a non-fatal problem with a file; a nil logger discards it
+func (l *WarningLogger) Warnf(folder, file, format string, args ...any) {
+	l.log("warning", "Warning", folder, file, fmt.Sprintf(format, args...))
+}
+
+// Errorf reports a problem that stopped a folder from being processed
+func (l *WarningLogger) Errorf(folder, file, format string, args ...any) {
+	l.log("error", "Error", folder, file, fmt.Sprintf(format, args...))
+}
+
+func (l *WarningLogger) log(level, label, folder, file, message string) {
+	if l == nil {
+		return
+	}
+
+	// Folders are scanned concurrently, so writes are serialized to keep lines intact
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.json {
+		fmt.Fprintf(l.out, "%s: %s\n", label, message)
+		return
+	}
+
+	data, err := json.Marshal(Diagnostic{Level: level, Folder: folder, File: file, Message: message})
+	if err != nil {
+		return
+	}
+	l.out.Write(append(data, '\n'))
+}
@@ -0,0 +1,876 @@
+package analyzer
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// fileWorkers is how many files of one folder are scanned at once. Cross-file de-duplication
+// (--id-regex) and collected lines (--es-bulk line mode) depend on file order, and --sequential
+// promises a reproducible warning order, so those keep to one file at a time.
+func fileWorkers(opts Options) int {
+	if opts.Sequential || opts.IDRegex != nil || opts.CollectEntries {
+		return 1
+	}
+	if opts.FileWorkers < 1 {
+		return runtime.NumCPU()
+	}
+	return opts.FileWorkers
+}
+
+// scanFolderFile opens one file of the folder, decompressing .gz files, and scans it
+func (s *folderScan) scanFolderFile(filePath string) {
+	folderPath, opts := s.result.FolderPath, s.opts
+
+	file, err := s.openWithRetry(filePath)
+	if err != nil {
+		// Log error but continue with other files
+		opts.Logger.Warnf(folderPath, filePath, "Error opening file %s: %v", filePath, err)
+		return
+	}
+	defer file.Close()
+
+	fileName := s.fileName(filePath)
+
+	// An archive among the folder's files is scanned entry by entry, e.g. as "logs.zip/app.txt"
+	if IsZipArchive(filePath) {
+		info, err := file.Stat()
+		if err != nil {
+			opts.Logger.Warnf(folderPath, filePath, "Error opening zip archive %s: %v", filePath, err)
+			return
+		}
+		archive, err := zip.NewReader(file, info.Size())
+		if err != nil {
+			opts.Logger.Warnf(folderPath, filePath, "Error opening zip archive %s: %v", filePath, err)
+			return
+		}
+		entries, err := zipLogEntries(archive, opts)
+		if err != nil {
+			opts.Logger.Warnf(folderPath, filePath, "Skipping zip archive %s: %v", filePath, err)
+			return
+		}
+		s.scanZipEntries(entries, filePath, fileName+"/")
+		return
+	}
+
+	var input io.Reader = file
+	if strings.HasSuffix(filePath, ".gz") {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			opts.Logger.Warnf(folderPath, filePath, "Error decompressing file %s: %v", filePath, err)
+			return
+		}
+		input = gzipReader
+	}
+
+	s.scanFile(input, filePath, fileName)
+}
+
+// transientOpenErrors are fragments of open errors worth retrying, mostly from flaky network
+// shares (the Windows messages are for SMB paths)
+var transientOpenErrors = []string{
+	"resource temporarily unavailable",
+	"interrupted system call",
+	"device or resource busy",
+	"input/output error",
+	"stale file handle",
+	"connection reset",
+	"connection timed out",
+	"host is down",
+	"network name is no longer available",
+	"unexpected network error",
+	"semaphore timeout period has expired",
+	"being used by another process",
+}
+
+// isTransientOpenError reports whether an open error may go away on its own. A missing file or
+// denied access won't, whatever the message says.
+func isTransientOpenError(err error) bool {
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+		return false
+	}
+	message := strings.ToLower(err.Error())
+	for _, fragment := range transientOpenErrors {
+		if strings.Contains(message, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// openWithRetry opens a file, retrying transient errors up to opts.Retries times with a delay
+// that doubles after each attempt. Only the open is retried; read errors mid-scan are not.
+func (s *folderScan) openWithRetry(filePath string) (*os.File, error) {
+	delay := s.opts.RetryDelay
+	for attempt := 0; ; attempt++ {
+		file, err := os.Open(filePath)
+		if err == nil || attempt >= s.opts.Retries || !isTransientOpenError(err) {
+			if err != nil && attempt > 0 {
+				err = fmt.Errorf("%w (gave up after %d retries)", err, attempt)
+			}
+			return file, err
+		}
+		select {
+		case <-s.ctx.Done():
+			return nil, err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// fileName is the name a file of the folder is listed under. Nested files are keyed by relative
+// path, since day folders often reuse base names.
+func (s *folderScan) fileName(filePath string) string {
+	if s.opts.Recursive {
+		if relative, err := filepath.Rel(s.result.FolderPath, filePath); err == nil && relative != "." {
+			return relative
+		}
+	}
+	return filepath.Base(filePath)
+}
+
+// scanPartial scans one file into a partial result of its own, to be merged into the folder's.
+// With --cache, an unchanged file's partial result is restored instead of scanned.
+func (s *folderScan) scanPartial(filePath string) *folderScan {
+	// Partial scans keep full hourly detail; --hourly-top-k is applied to the merged result
+	partialOpts := s.opts
+	partialOpts.HourlyTopK = 0
+	partial := newFolderResult(s.result.FolderPath, partialOpts)
+	partialScan := newFolderScan(s.ctx, &partial, partialOpts)
+
+	cache := s.opts.Cache
+	info, err := os.Stat(filePath)
+	cacheKey, absErr := filepath.Abs(filePath)
+	if cache == nil || err != nil || absErr != nil {
+		partialScan.scanFolderFile(filePath)
+		return partialScan
+	}
+
+	// A config pattern makes one folder's entries differ from another's for the same options
+	fileName := partialScan.fileName(filePath)
+	options := cache.fingerprint + "\x00" + strings.Join(partial.Patterns, "\x00")
+	if entry, ok := cache.lookup(cacheKey, info, options); ok {
+		entry.restore(partialScan, fileName)
+		return partialScan
+	}
+
+	partialScan.scanFolderFile(filePath)
+
+	// Files that couldn't be opened or were cut short by Ctrl+C or --strict are scanned again next time.
+	// A zip archive's counts are keyed by its entries ("logs.zip/app.txt"), never by the archive
+	// itself, so archives are not cached and are read again on every run.
+	if _, scanned := partial.FileCountMap[fileName]; scanned && partial.Error == nil {
+		cache.store(cacheKey, newCacheEntry(partialScan, fileName, info, options))
+	}
+	return partialScan
+}
+
+// scanFilesConcurrently scans files with a pool of workers. Each file is counted into its own
+// partial result, which is merged into the folder's under a mutex as soon as the file is done.
+func (s *folderScan) scanFilesConcurrently(files []string, workers int) {
+	jobs := make(chan string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for filePath := range jobs {
+				partialScan := s.scanPartial(filePath)
+
+				mu.Lock()
+				s.merge(partialScan)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, filePath := range files {
+		mu.Lock()
+		failed := s.result.Error != nil
+		mu.Unlock()
+		if s.ctx.Err() != nil || failed {
+			break
+		}
+		jobs <- filePath
+	}
+	close(jobs)
+	wg.Wait()
+
+	if s.ctx.Err() != nil {
+		s.result.Error = ErrCancelled
+	}
+}
+
+// merge adds the counts of a partial scan, covering other files of the same folder, into s
+func (s *folderScan) merge(partial *folderScan) {
+	result, other := s.result, partial.result
+
+	// A --strict failure in any file fails the folder
+	if result.Error == nil && other.Error != nil && other.Error != ErrCancelled {
+		result.Error = other.Error
+	}
+
+	result.TotalCount += other.TotalCount
+	result.DuplicateCount += other.DuplicateCount
+	result.SkippedCount += other.SkippedCount
+	result.LinesScanned += other.LinesScanned
+	result.BytesScanned += other.BytesScanned
+	for _, line := range other.SkippedSamples {
+		if len(result.SkippedSamples) < maxSkippedSamples {
+			result.SkippedSamples = append(result.SkippedSamples, line)
+		}
+	}
+	result.ScheduleAligned += other.ScheduleAligned
+	result.ScheduleOff += other.ScheduleOff
+	result.Entries = append(result.Entries, other.Entries...)
+	for minute, count := range other.MinuteOfHourCounts {
+		result.MinuteOfHourCounts[minute] += count
+	}
+
+	MergeCounts(result.DateCountMap, other.DateCountMap)
+	MergeCounts(result.FileCountMap, other.FileCountMap)
+	MergeCounts(result.DateUnknownHour, other.DateUnknownHour)
+	MergeCounts(result.ClientCountMap, other.ClientCountMap)
+	MergeCounts(result.DomainCountMap, other.DomainCountMap)
+
+	for date, hourlyData := range other.DateHourlyData {
+		if s.prunedDates[date] {
+			continue
+		}
+		if result.DateHourlyData[date] == nil {
+			result.DateHourlyData[date] = make(map[int]int)
+		}
+		for hour, count := range hourlyData {
+			result.DateHourlyData[date][hour] += count
+		}
+	}
+	for pattern, dates := range other.PatternDateCounts {
+		if result.PatternDateCounts[pattern] == nil {
+			result.PatternDateCounts[pattern] = make(map[string]int)
+		}
+		MergeCounts(result.PatternDateCounts[pattern], dates)
+	}
+	for date, buckets := range other.DateResultCounts {
+		if result.DateResultCounts[date] == nil {
+			result.DateResultCounts[date] = make(map[string]int)
+		}
+		MergeCounts(result.DateResultCounts[date], buckets)
+	}
+	for date, sketch := range other.DateRecipientSketch {
+		if result.DateRecipientSketch[date] == nil {
+			result.DateRecipientSketch[date] = NewHyperLogLog()
+		}
+		result.DateRecipientSketch[date].Merge(sketch)
+	}
+
+	for email := range other.Recipients {
+		result.Recipients[email] = true
+	}
+	for fileName := range other.CappedFiles {
+		result.CappedFiles[fileName] = true
+	}
+	for fileName := range other.AbortedFiles {
+		result.AbortedFiles[fileName] = true
+	}
+	for fileName, timeRange := range other.FileTimeRanges {
+		result.FileTimeRanges[fileName] = result.FileTimeRanges[fileName].merge(timeRange)
+	}
+	for fileName, dates := range partial.fileDateRanges {
+		s.fileDateRanges[fileName] = dates
+	}
+
+	if s.opts.HourlyTopK > 0 {
+		pruneHourlyData(result, s.opts.HourlyTopK, s.prunedDates)
+	}
+}
+
+// folderScan holds the state shared by all files of a folder while they are scanned
+type folderScan struct {
+	ctx    context.Context // cancelled by Ctrl+C
+	result *FolderResult
+	opts   Options
+
+	// Event IDs already counted, per date, when de-duplicating with --id-regex
+	seenIDs map[string]map[string]bool
+
+	// Earliest and latest matched date per file, for --check-overlap
+	fileDateRanges map[string]dateRange
+
+	// Dates whose hourly detail was dropped by --hourly-top-k and must not be rebuilt partially
+	prunedDates map[string]bool
+}
+
+func newFolderScan(ctx context.Context, result *FolderResult, opts Options) *folderScan {
+	return &folderScan{
+		ctx:            ctx,
+		result:         result,
+		opts:           opts,
+		seenIDs:        make(map[string]map[string]bool),
+		fileDateRanges: make(map[string]dateRange),
+		prunedDates:    make(map[string]bool),
+	}
+}
+
+// scanFile counts the matching lines of one log file into the folder result
+func (s *folderScan) scanFile(reader io.Reader, filePath, fileName string) {
+	result, opts := s.result, s.opts
+	fileCount := 0
+
+	// Matching lines seen and how many of them failed to parse, for --max-error-rate
+	candidateLines := 0
+	parseErrors := 0
+	sampleChecked := false
+
+	lineNumber := 0
+	scanner := bufio.NewScanner(decodeBOM(reader))
+	scanner.Buffer(make([]byte, 0, min(64*1024, opts.MaxLineBytes)), opts.MaxLineBytes)
+	scanner.Split(s.splitLines(filePath, &lineNumber))
+	for scanner.Scan() {
+		lineNumber++
+		result.LinesScanned++
+		result.BytesScanned += int64(len(scanner.Bytes()))
+
+		// Stop mid-file on Ctrl+C; slow network reads would otherwise delay the exit
+		select {
+		case <-s.ctx.Done():
+			result.Error = ErrCancelled
+			return
+		default:
+		}
+
+		line := scanner.Text()
+
+		// Cheap prefix test first, so unrelated lines never reach the pattern check
+		if opts.LinePrefix != "" && !strings.HasPrefix(line, opts.LinePrefix) {
+			continue
+		}
+
+		// Check if line contains the pattern (default "2FA - Email") or matches --regex
+		if result.MatchesLine(line) {
+			candidateLines++
+			parsed := false
+
+			// Extract the date and time from the line (format: YYYY-MM-DD HH:MM:SS),
+			// or from wherever --timestamp-layout finds one
+			dateStr, timeStr, found := splitTimestamp(line, opts.TimestampLayout, opts.Location)
+			if found {
+				// Parse date to ensure it's valid
+				date, err := time.Parse("2006-01-02", dateStr)
+				if err == nil {
+					parsed = true
+
+					// Entries outside --since/--until are not counted anywhere
+					if (!opts.Since.IsZero() && date.Before(opts.Since)) || (!opts.Until.IsZero() && date.After(opts.Until)) {
+						continue
+					}
+
+					// Extract hour and minute from time string (HH:MM:SS)
+					entryHour, entryMinute := -1, -1
+					timeParts := strings.Split(timeStr, ":")
+					if len(timeParts) >= 1 {
+						var hour int
+						_, err := fmt.Sscanf(timeParts[0], "%d", &hour)
+						if err == nil && hour >= 0 && hour <= 23 {
+							entryHour = hour
+						}
+					}
+					if len(timeParts) >= 2 {
+						var minute int
+						_, err := fmt.Sscanf(timeParts[1], "%d", &minute)
+						if err == nil && minute >= 0 && minute <= 59 {
+							entryMinute = minute
+						}
+					}
+
+					// Neither are entries in --exclude-hours; entries without an hour are kept
+					if entryHour >= 0 && opts.ExcludeHours[entryHour] {
+						continue
+					}
+
+					// Collapse repeated deliveries of the same event on the same day
+					if opts.IDRegex != nil {
+						if match := opts.IDRegex.FindStringSubmatch(line); len(match) > 1 && match[1] != "" {
+							if s.seenIDs[dateStr] == nil {
+								s.seenIDs[dateStr] = make(map[string]bool)
+							}
+							if s.seenIDs[dateStr][match[1]] {
+								result.DuplicateCount++
+								continue
+							}
+							s.seenIDs[dateStr][match[1]] = true
+						}
+					}
+
+					result.DateCountMap[dateStr]++
+					fileCount++
+					result.TotalCount++
+
+					// With several patterns, also count the entry toward each one it contains
+					if result.Regex == nil && len(result.Patterns) > 1 {
+						foldedLine := line
+						if result.IgnoreCase {
+							foldedLine = strings.ToLower(line)
+						}
+						for _, pattern := range result.Patterns {
+							if result.containsPattern(foldedLine, pattern) {
+								if result.PatternDateCounts[pattern] == nil {
+									result.PatternDateCounts[pattern] = make(map[string]int)
+								}
+								result.PatternDateCounts[pattern][dateStr]++
+							}
+						}
+					}
+
+					if opts.CheckOverlap {
+						s.fileDateRanges[fileName] = s.fileDateRanges[fileName].extend(dateStr)
+					}
+
+					// Bucket the entry by its result code, if one can be found
+					if opts.ResultRegex != nil {
+						bucket := ResultUnknown
+						if match := opts.ResultRegex.FindStringSubmatch(line); len(match) > 1 {
+							bucket = classifyResult(match[1])
+						}
+						if result.DateResultCounts[dateStr] == nil {
+							result.DateResultCounts[dateStr] = make(map[string]int)
+						}
+						result.DateResultCounts[dateStr][bucket]++
+					}
+
+					if opts.ClientRegex != nil {
+						client := clientUnknown
+						if match := opts.ClientRegex.FindStringSubmatch(line); len(match) > 1 && match[1] != "" {
+							client = match[1]
+						}
+						result.ClientCountMap[client]++
+					}
+
+					// Lines without an address still count, they just can't add a recipient
+					if email := extractEmail(line); email != "" {
+						// The exact set is what --approx-distinct avoids keeping in memory
+						if !opts.ApproxDistinct {
+							result.Recipients[email] = true
+						}
+						result.DomainCountMap[email[strings.LastIndex(email, "@")+1:]]++
+
+						// Track the recipient in this date's sketch when estimating distinct recipients
+						if opts.ApproxDistinct {
+							if result.DateRecipientSketch[dateStr] == nil {
+								result.DateRecipientSketch[dateStr] = NewHyperLogLog()
+							}
+							result.DateRecipientSketch[dateStr].Add(email)
+						}
+					}
+
+					// With minute buckets an entry needs both parts to be placed within its day
+					bucket := entryHour
+					if opts.MinuteBuckets {
+						bucket = -1
+						if entryHour >= 0 && entryMinute >= 0 {
+							bucket = entryHour*60 + entryMinute
+						}
+					}
+					if bucket < 0 {
+						result.DateUnknownHour[dateStr]++
+					} else if !s.prunedDates[dateStr] {
+						// Initialize map for this date if needed
+						if result.DateHourlyData[dateStr] == nil {
+							result.DateHourlyData[dateStr] = make(map[int]int)
+						}
+						result.DateHourlyData[dateStr][bucket]++
+					}
+
+					// Count the minute for spotting batch sends at a fixed minute
+					if opts.ByMinuteOfHour && entryMinute >= 0 {
+						result.MinuteOfHourCounts[entryMinute]++
+					}
+
+					timestamp, timeErr := time.Parse("2006-01-02 15:04:05", dateStr+" "+timeStr)
+					if timeErr == nil {
+						result.FileTimeRanges[fileName] = result.FileTimeRanges[fileName].extend(timestamp)
+					}
+
+					// Attribute the entry to a scheduled job if an activation is close enough
+					if opts.Schedule != nil && timeErr == nil {
+						if isNearActivation(opts.Schedule, timestamp, opts.ScheduleWindow) {
+							result.ScheduleAligned++
+						} else {
+							result.ScheduleOff++
+						}
+					}
+
+					if opts.CollectEntries || opts.OnEntry != nil {
+						entry := MatchedEntry{File: fileName, Date: dateStr, Hour: entryHour, Line: line}
+						if opts.CollectEntries {
+							result.Entries = append(result.Entries, entry)
+						}
+						if opts.OnEntry != nil {
+							opts.OnEntry(result.FolderPath, entry)
+						}
+					}
+				}
+			}
+
+			if !parsed {
+				// --strict treats a format change as a failure rather than something to count
+				if opts.Strict {
+					result.Error = fmt.Errorf("%s line %d: no valid date in matching line", fileName, lineNumber)
+					return
+				}
+				parseErrors++
+				result.SkippedCount++
+				if len(result.SkippedSamples) < maxSkippedSamples {
+					result.SkippedSamples = append(result.SkippedSamples, line)
+				}
+			}
+		}
+
+		// Give up early on files that are mostly unparseable, which usually means the wrong format
+		if opts.MaxErrorRate > 0 && !sampleChecked && candidateLines >= opts.ErrorSample {
+			sampleChecked = true
+			errorRate := float64(parseErrors) / float64(candidateLines)
+			if errorRate > opts.MaxErrorRate {
+				opts.Logger.Warnf(result.FolderPath, filePath, "Aborting file %s: %d of the first %d matching lines could not be parsed (%.0f%%, limit %.0f%%)",
+					filePath, parseErrors, candidateLines, errorRate*100, opts.MaxErrorRate*100)
+				result.AbortedFiles[fileName] = true
+				break
+			}
+		}
+
+		// Presence is confirmed once the cap is hit, so skip the rest of the file
+		if opts.MaxMatches > 0 && fileCount >= opts.MaxMatches {
+			result.CappedFiles[fileName] = true
+			break
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		opts.Logger.Warnf(result.FolderPath, filePath, "Error reading file %s: %v", filePath, err)
+	}
+
+	// Added rather than set, since --follow scans a growing file in several parts
+	result.FileCountMap[fileName] += fileCount
+
+	// Pruning after every file keeps at most K dates plus one file's worth of hourly maps in memory
+	if opts.HourlyTopK > 0 {
+		pruneHourlyData(result, opts.HourlyTopK, s.prunedDates)
+	}
+}
+
+// decodeBOM strips a UTF-8 byte order mark and decodes UTF-16 text marked by a UTF-16 BOM, as
+// exported by some .NET services, to UTF-8. Input without a BOM is returned byte for byte.
+func decodeBOM(reader io.Reader) io.Reader {
+	buffered := bufio.NewReader(reader)
+	start, _ := buffered.Peek(3)
+	if bytes.HasPrefix(start, []byte{0xEF, 0xBB, 0xBF}) {
+		buffered.Discard(3)
+		return buffered
+	}
+	encoding := detectEncoding(start)
+	if encoding != encodingUTF8 {
+		buffered.Discard(2)
+	}
+	return encoding.decode(buffered)
+}
+
+// textEncoding is a log file's encoding, as told by its byte order mark
+type textEncoding int
+
+const (
+	encodingUTF8 textEncoding = iota // UTF-8 or ASCII, with or without a BOM
+	encodingUTF16LE
+	encodingUTF16BE
+)
+
+// detectEncoding reads the encoding from the first bytes of a file
+func detectEncoding(start []byte) textEncoding {
+	switch {
+	case bytes.HasPrefix(start, []byte{0xFF, 0xFE}):
+		return encodingUTF16LE
+	case bytes.HasPrefix(start, []byte{0xFE, 0xFF}):
+		return encodingUTF16BE
+	default:
+		return encodingUTF8
+	}
+}
+
+// decode converts text in this encoding that doesn't start with a BOM, such as the lines --follow
+// finds appended to a file, to UTF-8
+func (e textEncoding) decode(reader io.Reader) io.Reader {
+	switch e {
+	case encodingUTF16LE:
+		return transform.NewReader(reader, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewDecoder())
+	case encodingUTF16BE:
+		return transform.NewReader(reader, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewDecoder())
+	default:
+		return reader
+	}
+}
+
+// splitLines is bufio.ScanLines, except that a line too long for the scanner's buffer is skipped
+// with a warning instead of ending the scan with bufio.ErrTooLong. lineNumber counts the skipped
+// line so later warnings still name the right line.
+func (s *folderScan) splitLines(filePath string, lineNumber *int) bufio.SplitFunc {
+	skipping := false
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		// Discard the rest of an overlong line, up to and including its newline
+		if skipping {
+			newline := bytes.IndexByte(data, '\n')
+			if newline < 0 {
+				return len(data), nil, nil
+			}
+			skipping = false
+			return newline + 1, nil, nil
+		}
+
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if advance == 0 && token == nil && err == nil && len(data) >= s.opts.MaxLineBytes {
+			*lineNumber++
+			s.opts.Logger.Warnf(s.result.FolderPath, filePath, "Skipping line %d of %s: longer than %d bytes (see --max-line-bytes)",
+				*lineNumber, filePath, s.opts.MaxLineBytes)
+			skipping = true
+			return len(data), nil, nil
+		}
+		return advance, token, err
+	}
+}
+
+// splitTimestamp returns the date and time-of-day text of a line's timestamp. Without a layout
+// they are the first two fields, or the first field when it is a combined RFC 3339 timestamp.
+// With one, the first substring starting at a word boundary that parses with the layout is used.
+// With a location (--tz) the timestamp is converted into it first; timestamps without an offset
+// are taken as UTC.
+func splitTimestamp(line, layout string, location *time.Location) (dateStr, timeStr string, found bool) {
+	if layout == "" {
+		parts := strings.Fields(line)
+
+		// "2024-03-01T14:32:01+02:00" is bucketed by the clock time written in the log, in its own offset
+		if len(parts) >= 1 && strings.IndexByte(parts[0], 'T') == len("2006-01-02") {
+			if timestamp, err := time.Parse(time.RFC3339, parts[0]); err == nil {
+				return formatTimestamp(timestamp, location)
+			}
+		}
+
+		if len(parts) < 2 {
+			return "", "", false
+		}
+
+		// Without --tz the fields are used as written, so a malformed time still leaves a valid date
+		if location != nil {
+			if timestamp, err := time.Parse("2006-01-02 15:04:05", parts[0]+" "+parts[1]); err == nil {
+				return formatTimestamp(timestamp, location)
+			}
+		}
+		return parts[0], parts[1], true
+	}
+
+	// Layouts with zero-padded fields match exactly len(layout) bytes of the line, or fewer when a
+	// UTC timestamp writes the layout's zone offset as "Z"
+	lengths := []int{len(layout)}
+	if zone := isoZoneLength(layout); zone > 1 {
+		lengths = append(lengths, len(layout)-zone+1)
+	}
+	for start := 0; start+lengths[len(lengths)-1] <= len(line); start++ {
+		if start > 0 && isWordByte(line[start-1]) {
+			continue
+		}
+		for _, length := range lengths {
+			if start+length > len(line) {
+				continue
+			}
+			if timestamp, err := time.Parse(layout, line[start:start+length]); err == nil {
+				return formatTimestamp(timestamp, location)
+			}
+		}
+	}
+	return "", "", false
+}
+
+// isoZoneLength returns the length of the layout's ISO 8601 zone offset ("Z07:00" and the like),
+// or 0 if it has none
+func isoZoneLength(layout string) int {
+	i := strings.Index(layout, "Z07")
+	if i < 0 {
+		return 0
+	}
+	for _, element := range []string{"Z07:00:00", "Z070000", "Z07:00", "Z0700", "Z07"} {
+		if strings.HasPrefix(layout[i:], element) {
+			return len(element)
+		}
+	}
+	return 0
+}
+
+// formatTimestamp splits a parsed timestamp into YYYY-MM-DD and HH:MM:SS, in location if one is given
+func formatTimestamp(timestamp time.Time, location *time.Location) (dateStr, timeStr string, found bool) {
+	if location != nil {
+		timestamp = timestamp.In(location)
+	}
+	return timestamp.Format("2006-01-02"), timestamp.Format("15:04:05"), true
+}
+
+// isWordByte reports whether b is an ASCII letter or digit, so a timestamp can't start right after it
+func isWordByte(b byte) bool {
+	return b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// finish runs the checks that need every file of the folder to have been scanned
+func (s *folderScan) finish() {
+	if s.opts.CheckOverlap {
+		s.result.OverlappingFiles = findOverlappingFiles(s.fileDateRanges)
+		for _, pair := range s.result.OverlappingFiles {
+			first, second := s.fileDateRanges[pair[0]], s.fileDateRanges[pair[1]]
+			s.opts.Logger.Warnf(s.result.FolderPath, filepath.Join(s.result.FolderPath, pair[0]), "Files %s (%s to %s) and %s (%s to %s) cover overlapping dates; check log rotation for duplicate ingestion",
+				pair[0], first.First, first.Last, pair[1], second.First, second.Last)
+		}
+	}
+}
+
+// isNearActivation reports whether the schedule fires within window of t, before or after it
+func isNearActivation(schedule cron.Schedule, t time.Time, window time.Duration) bool {
+	// Next is strictly after its argument, so start just before the window opens
+	next := schedule.Next(t.Add(-window - time.Nanosecond))
+	return !next.IsZero() && !next.After(t.Add(window))
+}
+
+// pruneHourlyData drops the hourly breakdown of all but the k busiest dates (ties keep the
+// earlier date) and records the dropped dates in pruned. Daily totals are untouched, so only
+// the per-hour detail is lost.
+func pruneHourlyData(result *FolderResult, k int, pruned map[string]bool) {
+	if len(result.DateHourlyData) <= k {
+		return
+	}
+
+	dates := KeysByCountDesc(result.DateCountMap)
+	keep := make(map[string]bool, k)
+	for _, date := range dates[:min(k, len(dates))] {
+		keep[date] = true
+	}
+
+	for date := range result.DateHourlyData {
+		if !keep[date] {
+			delete(result.DateHourlyData, date)
+			pruned[date] = true
+		}
+	}
+}
+
+// dateRange is the earliest and latest matched date (YYYY-MM-DD) in a file
+type dateRange struct {
+	First string
+	Last  string
+}
+
+// extend widens the range to include date
+func (r dateRange) extend(date string) dateRange {
+	if r.First == "" || date < r.First {
+		r.First = date
+	}
+	if r.Last == "" || date > r.Last {
+		r.Last = date
+	}
+	return r
+}
+
+// TimeRange is the earliest and latest timestamp of the entries counted from a file
+type TimeRange struct {
+	First time.Time
+	Last  time.Time
+}
+
+// extend widens the range to include t
+func (r TimeRange) extend(t time.Time) TimeRange {
+	if r.First.IsZero() || t.Before(r.First) {
+		r.First = t
+	}
+	if r.Last.IsZero() || t.After(r.Last) {
+		r.Last = t
+	}
+	return r
+}
+
+// merge widens the range to cover other as well
+func (r TimeRange) merge(other TimeRange) TimeRange {
+	if other.First.IsZero() {
+		return r
+	}
+	return r.extend(other.First).extend(other.Last)
+}
+
+// findOverlappingFiles returns every pair of files whose date ranges overlap, in file name order
+func findOverlappingFiles(fileDateRanges map[string]dateRange) [][2]string {
+	fileNames := SortedKeys(fileDateRanges)
+
+	var pairs [][2]string
+	for i, first := range fileNames {
+		for _, second := range fileNames[i+1:] {
+			a, b := fileDateRanges[first], fileDateRanges[second]
+			if a.First <= b.Last && b.First <= a.Last {
+				pairs = append(pairs, [2]string{first, second})
+			}
+		}
+	}
+	return pairs
+}
+
+// Result buckets for --result-field/--result-regex
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+	ResultUnknown = "unknown"
+)
+
+// clientUnknown groups entries whose line has no client field
+const clientUnknown = "unknown"
+
+// classifyResult maps a result code from a log line to a result bucket
+func classifyResult(value string) string {
+	switch strings.ToLower(strings.Trim(value, `"'.,;`)) {
+	case "success", "succeeded", "successful", "ok", "passed", "allowed", "accepted", "sent", "delivered":
+		return ResultSuccess
+	case "failure", "failed", "fail", "denied", "rejected", "blocked", "error", "expired", "invalid", "timeout":
+		return ResultFailure
+	default:
+		return ResultUnknown
+	}
+}
+
+// extractEmail returns the first token on the line that looks like an email address, lowercased
+func extractEmail(line string) string {
+	for _, field := range strings.Fields(line) {
+		if !strings.Contains(field, "@") {
+			continue
+		}
+
+		// Handle key=value tokens such as "to=user@example.com"
+		if idx := strings.LastIndex(field, "="); idx >= 0 {
+			field = field[idx+1:]
+		}
+		field = strings.Trim(field, "<>()[]{},;:'\"")
+
+		at := strings.Index(field, "@")
+		if at > 0 && at < len(field)-1 {
+			return strings.ToLower(field)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,195 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestSplitTimestampOffsets(t *testing.T) {
+	plusTwo := time.FixedZone("UTC+2", 2*60*60)
+	tests := []struct {
+		name     string
+		line     string
+		layout   string
+		location *time.Location
+		wantDate string
+		wantTime string
+	}{
+		{
+			name:     "+02:00 kept as written",
+			line:     "2024-03-01T01:30:00+02:00 2FA - Email sent",
+			wantDate: "2024-03-01",
+			wantTime: "01:30:00",
+		},
+		{
+			name:     "+02:00 back into the previous day",
+			line:     "2024-03-01T01:30:00+02:00 2FA - Email sent",
+			location: time.UTC,
+			wantDate: "2024-02-29",
+			wantTime: "23:30:00",
+		},
+		{
+			name:     "-05:00 kept as written",
+			line:     "2024-12-31T22:00:00-05:00 2FA - Email sent",
+			wantDate: "2024-12-31",
+			wantTime: "22:00:00",
+		},
+		{
+			name:     "-05:00 forward into the next year",
+			line:     "2024-12-31T22:00:00-05:00 2FA - Email sent",
+			location: time.UTC,
+			wantDate: "2025-01-01",
+			wantTime: "03:00:00",
+		},
+		{
+			name:     "Z forward into the next day",
+			line:     "2024-06-15T23:30:00Z 2FA - Email sent",
+			location: plusTwo,
+			wantDate: "2024-06-16",
+			wantTime: "01:30:00",
+		},
+		{
+			name:     "Z unchanged in UTC",
+			line:     "2024-06-15T23:30:00Z 2FA - Email sent",
+			location: time.UTC,
+			wantDate: "2024-06-15",
+			wantTime: "23:30:00",
+		},
+		{
+			name:     "fields without an offset taken as UTC",
+			line:     "2024-06-15 23:30:00 2FA - Email sent",
+			location: plusTwo,
+			wantDate: "2024-06-16",
+			wantTime: "01:30:00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dateStr, timeStr, found := splitTimestamp(tt.line, tt.layout, tt.location)
+			if !found {
+				t.Fatalf("splitTimestamp(%q) found no timestamp", tt.line)
+			}
+			if dateStr != tt.wantDate || timeStr != tt.wantTime {
+				t.Errorf("splitTimestamp(%q) = %s %s, want %s %s", tt.line, dateStr, timeStr, tt.wantDate, tt.wantTime)
+			}
+		})
+	}
+}
+
+func TestSplitTimestampLayoutZone(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		layout   string
+		wantDate string
+		wantTime string
+	}{
+		{
+			name:     "offset",
+			line:     "[mailer] ts=2024-02-28T21:15:00-05:00 2FA - Email sent",
+			layout:   time.RFC3339,
+			wantDate: "2024-02-29",
+			wantTime: "02:15:00",
+		},
+		{
+			name:     "Z",
+			line:     "[mailer] ts=2024-02-29T22:15:00Z 2FA - Email sent",
+			layout:   time.RFC3339,
+			wantDate: "2024-02-29",
+			wantTime: "22:15:00",
+		},
+		{
+			name:     "Z at the end of the line",
+			line:     "2FA - Email sent at 2024-02-29T22:15:00Z",
+			layout:   time.RFC3339,
+			wantDate: "2024-02-29",
+			wantTime: "22:15:00",
+		},
+		{
+			name:     "Z for an offset without a colon",
+			line:     "2FA - Email sent 20240229 221500Z",
+			layout:   "20060102 150405Z0700",
+			wantDate: "2024-02-29",
+			wantTime: "22:15:00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dateStr, timeStr, found := splitTimestamp(tt.line, tt.layout, time.UTC)
+			if !found {
+				t.Fatalf("splitTimestamp(%q, %q) found no timestamp", tt.line, tt.layout)
+			}
+			if dateStr != tt.wantDate || timeStr != tt.wantTime {
+				t.Errorf("splitTimestamp(%q, %q) = %s %s, want %s %s", tt.line, tt.layout, dateStr, timeStr, tt.wantDate, tt.wantTime)
+			}
+		})
+	}
+}
+
+func TestFileWorkersGiveIdenticalResults(t *testing.T) {
+	dir := t.TempDir()
+	files := make(map[string]string)
+	for i := range 24 {
+		var content string
+		for j := range 50 {
+			day := (i + j) % 9
+			result := []string{"Success", "Failure", "Pending"}[j%3]
+			client := []string{"ios-app/3.2", "web/1.0", ""}[(i+j)%3]
+			content += fmt.Sprintf("2024-06-%02d %02d:%02d:07 [INFO] 2FA - Email to user%d@example%d.com - Status: %s client=%s\n",
+				10+day, (i*7+j)%24, (i+j*13)%60, (i*j)%40, j%4, result, client)
+			if j%10 == 0 {
+				content += fmt.Sprintf("2024-06-%02d 12:00:00 [WARN] 2FA - SMS retry %d\n", 10+day, j)
+			}
+		}
+		files[fmt.Sprintf("log_%02d.txt", i)] = content
+	}
+	// One file of malformed dates, so the skipped samples don't depend on merge order
+	files["zz_bad.txt"] = "2024-06-99 10:00:00 2FA - Email\nsoon 2FA - Email\n"
+	writeFiles(t, dir, files)
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "defaults"},
+		{
+			name: "breakdowns",
+			opts: Options{
+				Patterns:       []string{"2FA - Email", "2FA - SMS"},
+				ResultRegex:    regexp.MustCompile(`Status: (\w+)`),
+				ClientRegex:    regexp.MustCompile(`client=(\S+)`),
+				CheckOverlap:   true,
+				ByMinuteOfHour: true,
+			},
+		},
+		{name: "minute buckets", opts: Options{MinuteBuckets: true, ApproxDistinct: true}},
+		{name: "hourly top k", opts: Options{HourlyTopK: 3}},
+		{name: "max matches", opts: Options{MaxMatches: 20}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			opts.FileWorkers = 1
+			want := ProcessFolder(context.Background(), filepath.Clean(dir), opts)
+			if want.Error != nil {
+				t.Fatalf("unexpected error: %v", want.Error)
+			}
+
+			opts.FileWorkers = 8
+			for range 5 {
+				got := ProcessFolder(context.Background(), filepath.Clean(dir), opts)
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("results with 8 file workers differ from 1:\n got %+v\nwant %+v", got, want)
+				}
+			}
+		})
+	}
+}